@@ -1,48 +1,69 @@
 package main
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"flag"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"os"
+	"sync"
+	"time"
+	_ "time/tzdata"
 
 	"github.com/mbndr/figlet4go"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/withmandala/go-log"
 	"gopkg.in/yaml.v3"
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
 )
 
 // Global variables
 var (
-	logger                   *log.Logger
-	temperatureGauge         *prometheus.GaugeVec
-	humidityGauge            *prometheus.GaugeVec
-	valveTappetGauge         *prometheus.GaugeVec
-	setpointTemperatureGauge *prometheus.GaugeVec
-	configPath               string
-	httpBind                 string
-	httpPort                 string
-	bshcHost                 string
-	bshcPort                 string
-	bshcClientCert           string
-	bshcClientKey            string
-	debug                    bool
-	configPathDefault        = "config/config.yaml"
-	httpBindDefault          = ""
-	httpPortDefault          = ""
-	bshcHostDefault          = ""
-	bshcPortDefault          = ""
-	bshcClientCertDefault    = ""
-	bshcClientKeyDefault     = ""
-	skipTlsVerify			bool
-	skipTlsVerifyDefault	= false
-	c                        conf
-	devices                  = make(map[string]interface{})
-	rooms                    = make(map[string]interface{})
+	logger                  *log.Logger
+	client                  *bshc.Client
+	snapshotAgeGauge        prometheus.GaugeFunc
+	configPath              string
+	httpBind                string
+	httpPort                string
+	bshcHost                string
+	bshcPort                string
+	bshcClientCert          string
+	bshcClientKey           string
+	debug                   bool
+	configPathDefault       = "config/config.yaml"
+	httpBindDefault         = ""
+	httpPortDefault         = ""
+	bshcHostDefault         = ""
+	bshcPortDefault         = ""
+	bshcClientCertDefault   = ""
+	bshcClientKeyDefault    = ""
+	bshcPublicPort          string
+	bshcPublicPortDefault   = bshc.DefaultPublicPort
+	skipTlsVerify           bool
+	skipTlsVerifyDefault    = false
+	bshcTimezone            string
+	bshcTimezoneDefault     = ""
+	bshcLocation            = time.Local
+	connectTimeout          time.Duration
+	connectTimeoutDefault   = bshc.DefaultConnectTimeout
+	readTimeout             time.Duration
+	readTimeoutDefault      = bshc.DefaultReadTimeout
+	pollInterval            time.Duration
+	pollIntervalDefault     = 30 * time.Second
+	topologyInterval        time.Duration
+	topologyIntervalDefault = 10 * time.Minute
+	longPolling             bool
+	longPollingDefault      = false
+	longPollTimeout         = 30
+	unsubscribeTimeout      = 5 * time.Second
+	discovery               bool
+	discoveryDefault        = false
+	c                       conf
+	current                 snapshot
+	topo                    topology
+	hist                    = newHistory()
 )
 
 // Config struct
@@ -53,18 +74,177 @@ type conf struct {
 	} `yaml:"http"`
 
 	BSHC struct {
-		Host       string `yaml:"host"`
-		Port       string `yaml:"port"`
-		ClientCert string `yaml:"client_cert"`
-		ClientKey  string `yaml:"client_key"`
-		SkipTLSVerify bool `yaml:"skip_tls_verify"`
+		Host           string        `yaml:"host"`
+		Port           string        `yaml:"port"`
+		ClientCert     string        `yaml:"client_cert"`
+		ClientKey      string        `yaml:"client_key"`
+		PublicPort     string        `yaml:"public_port"`
+		Timezone       string        `yaml:"timezone"`
+		SkipTLSVerify  bool          `yaml:"skip_tls_verify"`
+		ConnectTimeout time.Duration `yaml:"connect_timeout"`
+		ReadTimeout    time.Duration `yaml:"read_timeout"`
 	} `yaml:"bshc"`
 
 	SERVICES struct {
-		TemperatureLevel bool `yaml:"temperature_level"`
-		HumidityLevel    bool `yaml:"humidity_level"`
-		ValveTappet      bool `yaml:"valve_tappet"`
+		TemperatureLevel     bool `yaml:"temperature_level"`
+		HumidityLevel        bool `yaml:"humidity_level"`
+		ValveTappet          bool `yaml:"valve_tappet"`
+		PowerMeter           bool `yaml:"power_meter"`
+		PowerSwitch          bool `yaml:"power_switch"`
+		ShutterContact       bool `yaml:"shutter_contact"`
+		AirQuality           bool `yaml:"air_quality"`
+		SmokeDetector        bool `yaml:"smoke_detector"`
+		MotionDetector       bool `yaml:"motion_detector"`
+		ShutterControl       bool `yaml:"shutter_control"`
+		BatteryLevel         bool `yaml:"battery_level"`
+		DeviceFaults         bool `yaml:"device_faults"`
+		DeviceAvailability   bool `yaml:"device_availability"`
+		CommunicationQuality bool `yaml:"communication_quality"`
+		ClimateControl       bool `yaml:"climate_control"`
+		HeatingCircuit       bool `yaml:"heating_circuit"`
+		WaterLeakage         bool `yaml:"water_leakage"`
+		IntrusionDetection   bool `yaml:"intrusion_detection"`
+		LightControl         bool `yaml:"light_control"`
+		Thermostat           bool `yaml:"thermostat"`
+		DeviceInfo           bool `yaml:"device_info"`
+		Controller           bool `yaml:"controller"`
+		Rooms                bool `yaml:"rooms"`
+		Discovery            bool `yaml:"discovery"`
 	} `yaml:"services"`
+
+	METRICS []metricMapping `yaml:"metrics"`
+
+	POLLING struct {
+		Interval         time.Duration `yaml:"interval"`
+		TopologyInterval time.Duration `yaml:"topology_interval"`
+		LongPoll         bool          `yaml:"long_poll"`
+	} `yaml:"polling"`
+}
+
+// Snapshot of the last successful poll of the BSHC
+type snapshot struct {
+	mu            sync.RWMutex
+	services      []bshc.DeviceService
+	intrusion     *bshc.IntrusionSystemState
+	intrusionTime time.Time
+	info          *bshc.Information
+	infoTime      time.Time
+	updated       time.Time
+}
+
+// Replace the services of the snapshot
+func (s *snapshot) set(services []bshc.DeviceService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.services = services
+	s.updated = time.Now()
+}
+
+// Apply DeviceServiceData events to the snapshot
+func (s *snapshot) apply(events []bshc.DeviceService) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	services := make([]bshc.DeviceService, len(s.services))
+	copy(services, s.services)
+
+	for _, event := range events {
+		if event.Type != bshc.EventTypeDeviceServiceData {
+			continue
+		}
+
+		// Replace the service the event belongs to or add it if it is new
+		replaced := false
+		for i, service := range services {
+			if service.ID == event.ID && service.DeviceID == event.DeviceID {
+				services[i] = event
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			services = append(services, event)
+		}
+		logger.Debugf("Applied event for service %s of device %s", event.ID, event.DeviceID)
+	}
+
+	s.services = services
+	s.updated = time.Now()
+}
+
+// Services of the snapshot, which must not be modified
+func (s *snapshot) get() []bshc.DeviceService {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.services
+}
+
+// Replace the state of the intrusion detection system
+func (s *snapshot) setIntrusion(state *bshc.IntrusionSystemState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.intrusion = state
+	s.intrusionTime = time.Now()
+}
+
+// State of the intrusion detection system, nil if it has not been fetched
+func (s *snapshot) getIntrusion() *bshc.IntrusionSystemState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.intrusion
+}
+
+// Time the state of the intrusion detection system was last fetched
+func (s *snapshot) intrusionUpdated() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.intrusionTime
+}
+
+// Whether the state of the intrusion detection system is older than interval
+func (s *snapshot) intrusionDue(interval time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.intrusionTime) >= interval
+}
+
+// Replace the information about the BSHC
+func (s *snapshot) setInformation(info *bshc.Information) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = info
+	s.infoTime = time.Now()
+}
+
+// Information about the BSHC, nil if it has not been fetched
+func (s *snapshot) getInformation() *bshc.Information {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.info
+}
+
+// Time the information about the BSHC was last fetched
+func (s *snapshot) informationUpdated() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.infoTime
+}
+
+// Whether the information about the BSHC is older than interval
+func (s *snapshot) informationDue(interval time.Duration) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return time.Since(s.infoTime) >= interval
+}
+
+// Age of the snapshot in seconds, NaN if no poll succeeded yet
+func (s *snapshot) age() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.updated.IsZero() {
+		return math.NaN()
+	}
+	return time.Since(s.updated).Seconds()
 }
 
 // Load config file
@@ -94,407 +274,164 @@ func initLogger(debug bool) *log.Logger {
 	return logger
 }
 
-// Make GET request
-func makeGetRequest(url, clientCert, clientKey string) (*http.Response, error) {
-	logger.Debugf("Making GET request to URL: %s", url)
+func updateMetrics(ctx context.Context) error {
+	logger.Debug("Updating metrics")
 
-	// Load client cert
-	cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+	services, err := client.Services(ctx)
 	if err != nil {
-		logger.Errorf("Could not load client certificate: %v", err)
-		return nil, err
+		return fmt.Errorf("failed to get services: %w", err)
 	}
-	logger.Debug("Client certificate loaded successfully")
 
-	// Setup HTTPS client
-	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
+	topo.refreshUnknown(ctx, services)
+	current.set(services)
+	hist.observe(services, false)
+
+	if c.SERVICES.DeviceAvailability {
+		if err := topo.refreshStatus(ctx); err != nil {
+			logger.Errorf("Failed to refresh device status: %v", err)
+		}
 	}
-	transport := &http.Transport{TLSClientConfig: tlsConfig}
-	client := &http.Client{Transport: transport}
-	
-	if skipTlsVerify {
-		tlsConfig.InsecureSkipVerify = true
-		logger.Debug("TLS verification skipped")
-	} else {
-		tlsConfig.InsecureSkipVerify = false
-		logger.Debug("TLS verification enabled")
-	}	
-	
-	logger.Debug("HTTPS client configured successfully")
-
-	// Make GET request
-	resp, err := client.Get(url)
-	if err != nil {
-		logger.Errorf("Could not make GET request: %v", err)
-		return nil, err
+	if c.SERVICES.IntrusionDetection {
+		updateIntrusionState(ctx)
+	}
+	if c.SERVICES.Controller {
+		updateInformation(ctx)
 	}
-	logger.Infof("GET request to URL %s completed with status code: %d", url, resp.StatusCode)
 
-	return resp, nil
+	logger.Debug("Metrics updated successfully")
+	return nil
 }
 
-func getDeviceNames() {
-	logger.Info("Fetching device names")
-
-	// Make GET request to devices endpoint
-	devicesURL := fmt.Sprintf("https://%s:%s/smarthome/devices", bshcHost, bshcPort)
-	resp, err := makeGetRequest(devicesURL, bshcClientCert, bshcClientKey)
+// Update the state of the intrusion detection system
+func updateIntrusionState(ctx context.Context) {
+	state, err := client.IntrusionSystemState(ctx)
 	if err != nil {
-		logger.Errorf("Failed to get devices: %v", err)
+		logger.Errorf("Failed to get intrusion detection system state: %v", err)
 		return
 	}
-	defer resp.Body.Close()
 
-	// Check if response status code is 200
-	if resp.StatusCode != http.StatusOK {
-		logger.Errorf("Unexpected status code: %d", resp.StatusCode)
-		return
-	}
+	current.setIntrusion(state)
+	hist.observeIntrusion(state)
+}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+// Update the information about the BSHC
+func updateInformation(ctx context.Context) {
+	info, err := client.Information(ctx)
 	if err != nil {
-		logger.Errorf("Failed to read response body: %v", err)
+		logger.Errorf("Failed to get BSHC information: %v", err)
 		return
 	}
 
-	// Parse JSON response
-	var devicesArray []map[string]interface{}
-	err = json.Unmarshal(body, &devicesArray)
-	if err != nil {
-		logger.Errorf("Failed to unmarshal devices response: %v", err)
-		return
-	}
+	current.setInformation(info)
+}
 
-	// Save devices with ids, names, and room ids to a map
-	for _, device := range devicesArray {
-		if device["deviceModel"] == "VENTILATION_SERVICE" || device["deviceModel"] == "HUE_BRIDGE_MANAGER" {
-			continue
-		}
+// Poll the BSHC in the background and refresh the snapshot
+func pollMetrics(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-		deviceID, ok := device["id"].(string)
-		if !ok {
-			logger.Errorf("Invalid device id format for device: %v", device)
-			continue
-		}
-		deviceName, ok := device["name"].(string)
-		if !ok {
-			logger.Errorf("Invalid device name format for device: %v", device)
-			continue
-		}
-		roomID, ok := device["roomId"].(string)
-		if !ok {
-			logger.Errorf("Invalid room id format for device: %v", device)
-			continue
-		}
-		devices[deviceID] = map[string]string{
-			"name":   deviceName,
-			"roomId": roomID,
+	for range ticker.C {
+		// Abort requests still running when the next poll is due
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := updateMetrics(ctx); err != nil {
+			logger.Errorf("Failed to update metrics: %v", err)
 		}
-		logger.Debugf("Device added: ID=%s, Name=%s, RoomID=%s", deviceID, deviceName, roomID)
+		cancel()
 	}
-	logger.Info("Device names fetched successfully")
 }
 
-func getRoomNames() {
-	logger.Info("Fetching room names")
-
-	// Make GET request to rooms endpoint
-	roomsURL := fmt.Sprintf("https://%s:%s/smarthome/rooms", bshcHost, bshcPort)
-	resp, err := makeGetRequest(roomsURL, bshcClientCert, bshcClientKey)
-	if err != nil {
-		logger.Errorf("Failed to get rooms: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check if response status code is 200
-	if resp.StatusCode != http.StatusOK {
-		logger.Errorf("Unexpected status code: %d", resp.StatusCode)
-		return
-	}
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Errorf("Failed to read response body: %v", err)
-		return
-	}
-
-	// Parse JSON response
-	var roomsArray []map[string]interface{}
-	err = json.Unmarshal(body, &roomsArray)
-	if err != nil {
-		logger.Errorf("Failed to unmarshal rooms response: %v", err)
-		return
-	}
-
-	// Save rooms with ids and names to a map
-	for _, room := range roomsArray {
-		roomID, ok := room["id"].(string)
-		if !ok {
-			logger.Errorf("Invalid room id format for room: %v", room)
-			continue
-		}
-		roomName, ok := room["name"].(string)
-		if !ok {
-			logger.Errorf("Invalid room name format for room: %v", room)
-			continue
+// Whether one of the events belongs to a service
+func hasService(events []bshc.DeviceService, serviceID string) bool {
+	for _, event := range events {
+		if event.ID == serviceID {
+			return true
 		}
-		rooms[roomID] = roomName
-		logger.Debugf("Room added: ID=%s, Name=%s", roomID, roomName)
 	}
-	logger.Info("Room names fetched successfully")
+	return false
 }
 
-func updateMetrics() {
-	logger.Debug("Updating metrics")
-
-	// Construct URL for services endpoint
-	servicesURL := fmt.Sprintf("https://%s:%s/smarthome/services", bshcHost, bshcPort)
-
-	// Make GET request to services endpoint
-	resp, err := makeGetRequest(servicesURL, bshcClientCert, bshcClientKey)
-	if err != nil {
-		logger.Errorf("Failed to get services: %v", err)
-		return
-	}
-	defer resp.Body.Close()
-
-	// Check if response status code is 200
-	if resp.StatusCode != http.StatusOK {
-		logger.Errorf("Unexpected status code: %d", resp.StatusCode)
-		return
-	}
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		logger.Errorf("Failed to read response body: %v", err)
-		return
-	}
-
-	// Parse JSON response
-	var services []map[string]interface{}
-	err = json.Unmarshal(body, &services)
-	if err != nil {
-		logger.Errorf("Failed to unmarshal services response: %v", err)
-		return
-	}
-
-	// Filter services with ID "TemperatureLevel"
-	if c.SERVICES.TemperatureLevel {
-		logger.Debug("Processing TemperatureLevel services")
-		var temperatureLevelServices []map[string]interface{}
-		for _, service := range services {
-			if service["id"] == "TemperatureLevel" {
-				state, ok := service["state"].(map[string]interface{})
-				if !ok {
-					logger.Errorf("Invalid state format for service: %v", service)
-					continue
-				}
-				filteredService := map[string]interface{}{
-					"id":          service["id"],
-					"deviceId":    service["deviceId"],
-					"temperature": state["temperature"],
-				}
-				temperatureLevelServices = append(temperatureLevelServices, filteredService)
-			}
+// Events reporting a changed service state, leaving out events about devices,
+// messages and the like
+func serviceData(events []bshc.DeviceService) []bshc.DeviceService {
+	services := make([]bshc.DeviceService, 0, len(events))
+	for _, event := range events {
+		if event.Type == bshc.EventTypeDeviceServiceData {
+			services = append(services, event)
 		}
+	}
+	return services
+}
 
-		// Update Prometheus metrics with temperature levels
-		for _, service := range temperatureLevelServices {
-			deviceID, ok := service["deviceId"].(string)
-			if !ok {
-				logger.Errorf("Invalid deviceId format for service: %v", service)
-				continue
-			}
-			temperature, ok := service["temperature"].(float64)
-			if !ok {
-				logger.Errorf("Invalid temperature format for service: %v", service)
-				continue
-			}
-			deviceName, ok := devices[deviceID].(map[string]string)["name"]
-			if !ok {
-				logger.Errorf("Invalid device name format for device: %v", deviceID)
-				continue
-			}
-			roomID, ok := devices[deviceID].(map[string]string)["roomId"]
-			if !ok {
-				logger.Errorf("Invalid room ID format for device: %v", deviceID)
-				continue
-			}
-			roomName, ok := rooms[roomID].(string)
-			if !ok {
-				logger.Errorf("Invalid room name format for room: %v", roomID)
-				continue
-			}
-
-			logger.Debugf("Updating temperature metric for device %s in room %s", deviceName, roomName)
-			temperatureGauge.WithLabelValues(deviceID, deviceName, roomName).Set(temperature)
-		}
-
-		// Filter services with ID "RoomClimateControl"
-		var setpointTemperatureLevelServices []map[string]interface{}
-		for _, service := range services {
-			if service["id"] == "RoomClimateControl" {
-				state, ok := service["state"].(map[string]interface{})
-				if !ok {
-					logger.Errorf("Invalid state format for service: %v", service)
-					continue
-				}
-				filteredService := map[string]interface{}{
-					"id":                  service["id"],
-					"deviceId":            service["deviceId"],
-					"setpointTemperature": state["setpointTemperature"],
-				}
-				setpointTemperatureLevelServices = append(setpointTemperatureLevelServices, filteredService)
+// Apply events from the BSHC event stream to the snapshot and fall back to
+// full fetches with the given interval while no subscription is active
+func longPollMetrics(interval time.Duration) {
+	ctx := context.Background()
+	for {
+		subscriptionID, err := client.Subscribe(ctx)
+		if err != nil {
+			logger.Errorf("Failed to subscribe to BSHC events: %v", err)
+
+			// Fall back to a full fetch before subscribing again
+			if err := updateMetrics(ctx); err != nil {
+				logger.Errorf("Failed to update metrics: %v", err)
 			}
+			time.Sleep(interval)
+			continue
 		}
+		logger.Infof("Subscribed to BSHC events with subscription %s", subscriptionID)
 
-		// Update Prometheus metrics with desired temperature levels
-		for _, service := range setpointTemperatureLevelServices {
-			deviceID, ok := service["deviceId"].(string)
-			if !ok {
-				logger.Errorf("Invalid deviceId format for service: %v", service)
-				continue
-			}
-			setpointTemperature, ok := service["setpointTemperature"].(float64)
-			if !ok {
-				logger.Errorf("Invalid setpointTemperature format for service: %v", service)
-				continue
-			}
-			deviceName, ok := devices[deviceID].(map[string]string)["name"]
-			if !ok {
-				logger.Errorf("Invalid device name format for device: %v", deviceID)
-				continue
-			}
-			roomID, ok := devices[deviceID].(map[string]string)["roomId"]
-			if !ok {
-				logger.Errorf("Invalid room ID format for device: %v", deviceID)
-				continue
-			}
-			roomName, ok := rooms[roomID].(string)
-			if !ok {
-				logger.Errorf("Invalid room name format for room: %v", roomID)
-				continue
-			}
-
-			logger.Debugf("Updating temperature metric for device %s in room %s", deviceName, roomName)
-			setpointTemperatureGauge.WithLabelValues(deviceID, deviceName, roomName).Set(setpointTemperature)
+		// Catch up on changes missed while not subscribed
+		if err := updateMetrics(ctx); err != nil {
+			logger.Errorf("Failed to update metrics: %v", err)
 		}
-	}
 
-	// Filter services with ID "HumidityLevel"
-	if c.SERVICES.HumidityLevel {
-		logger.Debug("Processing HumidityLevel services")
-		var humidityLevelServices []map[string]interface{}
-		for _, service := range services {
-			if service["id"] == "HumidityLevel" {
-				state, ok := service["state"].(map[string]interface{})
-				if !ok {
-					logger.Errorf("Invalid state format for service: %v", service)
-					continue
-				}
-				filteredService := map[string]interface{}{
-					"id":       service["id"],
-					"deviceId": service["deviceId"],
-					"humidity": state["humidity"],
-				}
-				humidityLevelServices = append(humidityLevelServices, filteredService)
+		for {
+			events, err := client.LongPoll(ctx, subscriptionID, longPollTimeout)
+			if err != nil {
+				logger.Errorf("BSHC event subscription dropped: %v", err)
+				break
 			}
-		}
-
-		// Update Prometheus metrics with humidity levels
-		for _, service := range humidityLevelServices {
-			deviceID, ok := service["deviceId"].(string)
-			if !ok {
-				logger.Errorf("Invalid deviceId format for service: %v", service)
-				continue
+			if len(events) > 0 {
+				logger.Debugf("Received %d events from BSHC", len(events))
 			}
-			humidity, ok := service["humidity"].(float64)
-			if !ok {
-				logger.Errorf("Invalid humidity format for service: %v", service)
-				continue
+			services := serviceData(events)
+			topo.refreshUnknown(ctx, services)
+			current.apply(services)
+			hist.observe(services, true)
+
+			// The system state is not part of the events, fetch it when it changed
+			// and regularly to catch changes not reported by these services
+			if c.SERVICES.IntrusionDetection && (hasService(services, "IntrusionDetectionControl") || hasService(services, "SurveillanceAlarm") || current.intrusionDue(interval)) {
+				updateIntrusionState(ctx)
 			}
 
-			deviceName, ok := devices[deviceID].(map[string]string)["name"]
-			if !ok {
-				logger.Errorf("Invalid device name format for device: %v", deviceID)
-				continue
-			}
-			roomID, ok := devices[deviceID].(map[string]string)["roomId"]
-			if !ok {
-				logger.Errorf("Invalid room ID format for device: %v", deviceID)
-				continue
-			}
-			roomName, ok := rooms[roomID].(string)
-			if !ok {
-				logger.Errorf("Invalid room name format for room: %v", roomID)
-				continue
+			// Updates of the BSHC itself are not part of the events either
+			if c.SERVICES.Controller && current.informationDue(interval) {
+				updateInformation(ctx)
 			}
 
-			logger.Debugf("Updating humidity metric for device %s in room %s", deviceName, roomName)
-			humidityGauge.WithLabelValues(deviceID, deviceName, roomName).Set(humidity)
-		}
-	}
-
-	// Filter services with ID "ValveTappet"
-	if c.SERVICES.ValveTappet {
-		logger.Debug("Processing ValveTappet services")
-		var valveTappetServices []map[string]interface{}
-		for _, service := range services {
-			if service["id"] == "ValveTappet" {
-				state, ok := service["state"].(map[string]interface{})
-				if !ok {
-					logger.Errorf("Invalid state format for service: %v", service)
-					continue
-				}
-				filteredService := map[string]interface{}{
-					"id":       service["id"],
-					"deviceId": service["deviceId"],
-					"position": state["position"],
+			// Neither is the status of the devices
+			if c.SERVICES.DeviceAvailability && topo.statusDue(interval) {
+				if err := topo.refreshStatus(ctx); err != nil {
+					logger.Errorf("Failed to refresh device status: %v", err)
 				}
-				valveTappetServices = append(valveTappetServices, filteredService)
 			}
 		}
 
-		// Update Prometheus metrics with valve tappet
-		for _, service := range valveTappetServices {
-			deviceID, ok := service["deviceId"].(string)
-			if !ok {
-				logger.Errorf("Invalid deviceId format for service: %v", service)
-				continue
-			}
-			valve, ok := service["position"].(float64)
-			if !ok {
-				logger.Errorf("Invalid position format for service: %v", service)
-				continue
-			}
-
-			deviceName, ok := devices[deviceID].(map[string]string)["name"]
-			if !ok {
-				logger.Errorf("Invalid device name format for device: %v", deviceID)
-				continue
-			}
-			roomID, ok := devices[deviceID].(map[string]string)["roomId"]
-			if !ok {
-				logger.Errorf("Invalid room ID format for device: %v", deviceID)
-				continue
-			}
-			roomName, ok := rooms[roomID].(string)
-			if !ok {
-				logger.Errorf("Invalid room name format for room: %v", roomID)
-				continue
-			}
-
-			logger.Debugf("Updating valve tappet metric for device %s in room %s", deviceName, roomName)
-			valveTappetGauge.WithLabelValues(deviceID, deviceName, roomName).Set(valve)
-		}
+		// The subscription may still be alive on the BSHC, end it before
+		// subscribing again, which catches up on missed changes
+		unsubscribe(subscriptionID)
+		time.Sleep(interval)
 	}
+}
 
-	logger.Debug("Metrics updated successfully")
+// End a subscription to the BSHC event stream, failures are only logged
+func unsubscribe(subscriptionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), unsubscribeTimeout)
+	defer cancel()
+	if err := client.Unsubscribe(ctx, subscriptionID); err != nil {
+		logger.Debugf("Failed to unsubscribe from BSHC events: %v", err)
+	}
 }
 
 func main() {
@@ -523,8 +460,24 @@ func main() {
 	flag.StringVar(&bshcClientCert, "clientcert", bshcClientCertDefault, "BSHC client cert")
 	flag.StringVar(&bshcClientKey, "ck", bshcClientKeyDefault, "BSHC client key")
 	flag.StringVar(&bshcClientKey, "clientkey", bshcClientKeyDefault, "BSHC client key")
+	flag.StringVar(&bshcPublicPort, "pp", bshcPublicPortDefault, "BSHC public API port")
+	flag.StringVar(&bshcPublicPort, "publicport", bshcPublicPortDefault, "BSHC public API port")
+	flag.StringVar(&bshcTimezone, "tz", bshcTimezoneDefault, "Time zone of the BSHC, e.g. Europe/Berlin")
+	flag.StringVar(&bshcTimezone, "timezone", bshcTimezoneDefault, "Time zone of the BSHC, e.g. Europe/Berlin")
 	flag.BoolVar(&skipTlsVerify, "insecure", false, "Skip TLS verification")
 	flag.BoolVar(&skipTlsVerify, "i", false, "Skip TLS verification")
+	flag.DurationVar(&connectTimeout, "ct", connectTimeoutDefault, "Timeout for connecting to the BSHC")
+	flag.DurationVar(&connectTimeout, "connecttimeout", connectTimeoutDefault, "Timeout for connecting to the BSHC")
+	flag.DurationVar(&readTimeout, "rt", readTimeoutDefault, "Timeout for requests to the BSHC")
+	flag.DurationVar(&readTimeout, "readtimeout", readTimeoutDefault, "Timeout for requests to the BSHC")
+	flag.DurationVar(&pollInterval, "pi", pollIntervalDefault, "Interval to poll the BSHC")
+	flag.DurationVar(&pollInterval, "pollinterval", pollIntervalDefault, "Interval to poll the BSHC")
+	flag.DurationVar(&topologyInterval, "ti", topologyIntervalDefault, "Interval to refresh devices and rooms")
+	flag.DurationVar(&topologyInterval, "topologyinterval", topologyIntervalDefault, "Interval to refresh devices and rooms")
+	flag.BoolVar(&longPolling, "lp", longPollingDefault, "Subscribe to the BSHC event stream instead of polling")
+	flag.BoolVar(&longPolling, "longpoll", longPollingDefault, "Subscribe to the BSHC event stream instead of polling")
+	flag.BoolVar(&discovery, "ds", discoveryDefault, "Export every field of all service states")
+	flag.BoolVar(&discovery, "discovery", discoveryDefault, "Export every field of all service states")
 	flag.BoolVar(&debug, "d", debug, "Enable debug mode")
 	flag.BoolVar(&debug, "debug", debug, "Enable debug mode")
 	flag.Parse()
@@ -558,15 +511,49 @@ func main() {
 		if flag.Lookup("clientkey").Value.String() == bshcClientKeyDefault || flag.Lookup("ck").Value.String() == bshcClientKeyDefault && c.BSHC.ClientKey != bshcClientKeyDefault {
 			bshcClientKey = c.BSHC.ClientKey
 		}
+		if (flag.Lookup("publicport").Value.String() == bshcPublicPortDefault || flag.Lookup("pp").Value.String() == bshcPublicPortDefault) && c.BSHC.PublicPort != "" {
+			bshcPublicPort = c.BSHC.PublicPort
+		}
+		if (flag.Lookup("timezone").Value.String() == bshcTimezoneDefault || flag.Lookup("tz").Value.String() == bshcTimezoneDefault) && c.BSHC.Timezone != bshcTimezoneDefault {
+			bshcTimezone = c.BSHC.Timezone
+		}
 		if flag.Lookup("insecure").Value.String() == fmt.Sprint(skipTlsVerifyDefault) || flag.Lookup("i").Value.String() == fmt.Sprint(skipTlsVerifyDefault) && c.BSHC.SkipTLSVerify != skipTlsVerifyDefault {
 			skipTlsVerify = c.BSHC.SkipTLSVerify
 		}
+		if (flag.Lookup("connecttimeout").Value.String() == connectTimeoutDefault.String() || flag.Lookup("ct").Value.String() == connectTimeoutDefault.String()) && c.BSHC.ConnectTimeout != 0 {
+			connectTimeout = c.BSHC.ConnectTimeout
+		}
+		if (flag.Lookup("readtimeout").Value.String() == readTimeoutDefault.String() || flag.Lookup("rt").Value.String() == readTimeoutDefault.String()) && c.BSHC.ReadTimeout != 0 {
+			readTimeout = c.BSHC.ReadTimeout
+		}
+		if (flag.Lookup("pollinterval").Value.String() == pollIntervalDefault.String() || flag.Lookup("pi").Value.String() == pollIntervalDefault.String()) && c.POLLING.Interval != 0 {
+			pollInterval = c.POLLING.Interval
+		}
+		if (flag.Lookup("topologyinterval").Value.String() == topologyIntervalDefault.String() || flag.Lookup("ti").Value.String() == topologyIntervalDefault.String()) && c.POLLING.TopologyInterval != 0 {
+			topologyInterval = c.POLLING.TopologyInterval
+		}
+		if (flag.Lookup("longpoll").Value.String() == fmt.Sprint(longPollingDefault) || flag.Lookup("lp").Value.String() == fmt.Sprint(longPollingDefault)) && c.POLLING.LongPoll != longPollingDefault {
+			longPolling = c.POLLING.LongPoll
+		}
+		if (flag.Lookup("discovery").Value.String() == fmt.Sprint(discoveryDefault) || flag.Lookup("ds").Value.String() == fmt.Sprint(discoveryDefault)) && c.SERVICES.Discovery != discoveryDefault {
+			discovery = c.SERVICES.Discovery
+		}
 	}
 
 	// Check if required config values are set
 	if httpBind == "" || httpPort == "" || bshcHost == "" || bshcPort == "" || bshcClientCert == "" || bshcClientKey == "" {
 		logger.Fatal("Missing required config values")
 	}
+	if pollInterval <= 0 || topologyInterval <= 0 {
+		logger.Fatal("Poll intervals must be greater than zero")
+	}
+	if bshcTimezone != "" {
+		location, err := time.LoadLocation(bshcTimezone)
+		if err != nil {
+			logger.Fatalf("Invalid time zone: %v", err)
+		}
+		bshcLocation = location
+	}
 
 	// DEBUG: Print config values
 	logger.Debug("Config Path: " + configPath)
@@ -576,60 +563,100 @@ func main() {
 	logger.Debug("BSHC Port: " + fmt.Sprint(bshcPort))
 	logger.Debug("BSHC Client Cert: " + bshcClientCert)
 	logger.Debug("BSHC Client Key: " + bshcClientKey)
+	logger.Debug("BSHC Public Port: " + bshcPublicPort)
+	logger.Debug("BSHC Time Zone: " + bshcLocation.String())
+	logger.Debug("BSHC Connect Timeout: " + connectTimeout.String())
+	logger.Debug("BSHC Read Timeout: " + readTimeout.String())
+	logger.Debug("Poll Interval: " + pollInterval.String())
+	logger.Debug("Topology Interval: " + topologyInterval.String())
+	logger.Debug("Long Polling: " + fmt.Sprint(longPolling))
 	logger.Debug("Temperature Level: " + fmt.Sprint(c.SERVICES.TemperatureLevel))
 	logger.Debug("Humidity Level: " + fmt.Sprint(c.SERVICES.HumidityLevel))
 	logger.Debug("Valve Tappet: " + fmt.Sprint(c.SERVICES.ValveTappet))
+	logger.Debug("Power Meter: " + fmt.Sprint(c.SERVICES.PowerMeter))
+	logger.Debug("Power Switch: " + fmt.Sprint(c.SERVICES.PowerSwitch))
+	logger.Debug("Shutter Contact: " + fmt.Sprint(c.SERVICES.ShutterContact))
+	logger.Debug("Air Quality: " + fmt.Sprint(c.SERVICES.AirQuality))
+	logger.Debug("Smoke Detector: " + fmt.Sprint(c.SERVICES.SmokeDetector))
+	logger.Debug("Motion Detector: " + fmt.Sprint(c.SERVICES.MotionDetector))
+	logger.Debug("Shutter Control: " + fmt.Sprint(c.SERVICES.ShutterControl))
+	logger.Debug("Battery Level: " + fmt.Sprint(c.SERVICES.BatteryLevel))
+	logger.Debug("Device Faults: " + fmt.Sprint(c.SERVICES.DeviceFaults))
+	logger.Debug("Device Availability: " + fmt.Sprint(c.SERVICES.DeviceAvailability))
+	logger.Debug("Communication Quality: " + fmt.Sprint(c.SERVICES.CommunicationQuality))
+	logger.Debug("Climate Control: " + fmt.Sprint(c.SERVICES.ClimateControl))
+	logger.Debug("Heating Circuit: " + fmt.Sprint(c.SERVICES.HeatingCircuit))
+	logger.Debug("Water Leakage: " + fmt.Sprint(c.SERVICES.WaterLeakage))
+	logger.Debug("Intrusion Detection: " + fmt.Sprint(c.SERVICES.IntrusionDetection))
+	logger.Debug("Light Control: " + fmt.Sprint(c.SERVICES.LightControl))
+	logger.Debug("Thermostat: " + fmt.Sprint(c.SERVICES.Thermostat))
+	logger.Debug("Device Info: " + fmt.Sprint(c.SERVICES.DeviceInfo))
+	logger.Debug("Controller: " + fmt.Sprint(c.SERVICES.Controller))
+	logger.Debug("Rooms: " + fmt.Sprint(c.SERVICES.Rooms))
+	logger.Debug("Discovery: " + fmt.Sprint(discovery))
+	logger.Debug("Metrics from config file: " + fmt.Sprint(len(c.METRICS)))
+
+	// Setup BSHC client
+	client = bshc.NewClient(bshcHost, bshcPort, bshcClientCert, bshcClientKey, skipTlsVerify,
+		bshc.WithConnectTimeout(connectTimeout),
+		bshc.WithReadTimeout(readTimeout),
+		bshc.WithPublicPort(bshcPublicPort),
+	)
 
-	// Get device names
-	getDeviceNames()
-	getRoomNames()
+	// Get devices and rooms
+	if err := topo.refresh(context.Background()); err != nil {
+		logger.Errorf("Failed to fetch devices and rooms: %v", err)
+	}
 
 	// Define Prometheus metrics
-	temperatureGauge = prometheus.NewGaugeVec(
+	snapshotAgeGauge = prometheus.NewGaugeFunc(
 		prometheus.GaugeOpts{
-			Name: "temperature_level",
-			Help: "Temperature level of the devices",
+			Name: "bshc_snapshot_age_seconds",
+			Help: "Age of the last successful poll of the BSHC in seconds",
 		},
-		[]string{"device_id", "device_name", "room_name"},
+		current.age,
 	)
 
-	setpointTemperatureGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "setpoint_temperature_level",
-			Help: "Desired temperature level of the devices",
-		},
-		[]string{"device_id", "device_name", "room_name"},
-	)
+	// Register Prometheus metrics
+	logger.Info("Registering Prometheus metrics")
+	prometheus.MustRegister(snapshotCollector{})
+	prometheus.MustRegister(snapshotAgeGauge)
 
-	humidityGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "humidity_level",
-			Help: "Humidity level of the devices",
-		},
-		[]string{"device_id", "device_name", "room_name"},
-	)
+	// Register catch-all metrics of discovery mode
+	if discovery {
+		logger.Info("Discovery mode enabled, exporting every field of all service states")
+		prometheus.MustRegister(discoveryCollector{})
+	}
 
-	valveTappetGauge = prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Name: "valve_tappet",
-			Help: "Valve tappet of the devices",
-		},
-		[]string{"device_id", "device_name", "room_name"},
-	)
+	// Register metrics defined in the config file
+	if len(c.METRICS) > 0 {
+		mappings, err := newMappingCollector(c.METRICS)
+		if err != nil {
+			logger.Fatalf("Invalid metrics in config file: %v", err)
+		}
+		if err := prometheus.Register(mappings); err != nil {
+			logger.Fatalf("Failed to register metrics from config file: %v", err)
+		}
+	}
 
-	// Register Prometheus metrics
-	logger.Info("Registering Prometheus metrics")
-	prometheus.MustRegister(temperatureGauge)
-	prometheus.MustRegister(setpointTemperatureGauge)
-	prometheus.MustRegister(humidityGauge)
-	prometheus.MustRegister(valveTappetGauge)
-
-	// HTTP handler for Prometheus metrics
-	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		logger.Debug("Handling /metrics request")
-		updateMetrics()
-		promhttp.Handler().ServeHTTP(w, r)
-	})
+	// Initial poll before serving metrics
+	if err := updateMetrics(context.Background()); err != nil {
+		logger.Errorf("Failed to update metrics: %v", err)
+	}
+
+	// Start background pollers
+	logger.Infof("Refreshing devices and rooms every %s", topologyInterval)
+	go refreshTopology(topologyInterval)
+	if longPolling {
+		logger.Info("Subscribing to BSHC event stream")
+		go longPollMetrics(pollInterval)
+	} else {
+		logger.Infof("Polling BSHC every %s", pollInterval)
+		go pollMetrics(pollInterval)
+	}
+
+	// HTTP handler for Prometheus metrics, served from the snapshot
+	http.Handle("/metrics", promhttp.Handler())
 
 	// Start HTTP server for Prometheus metrics
 	logger.Infof("Starting HTTP server on %s:%s", httpBind, httpPort)
@@ -0,0 +1,63 @@
+package bshc
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certificate holds the client key pair and reloads it when the files change
+// on disk.
+type certificate struct {
+	certFile string
+	keyFile  string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+// reload loads the key pair if it has not been loaded yet or one of the files
+// has been modified since and reports whether it did.
+func (c *certificate) reload() (bool, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return false, fmt.Errorf("could not stat client certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("could not stat client key: %w", err)
+	}
+
+	c.mu.RLock()
+	unchanged := c.cert != nil && certInfo.ModTime().Equal(c.certTime) && keyInfo.ModTime().Equal(c.keyTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("could not load client certificate: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.certTime = certInfo.ModTime()
+	c.keyTime = keyInfo.ModTime()
+	return true, nil
+}
+
+// get returns the current key pair for a TLS handshake.
+func (c *certificate) get(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.cert == nil {
+		return nil, fmt.Errorf("client certificate not loaded")
+	}
+	return c.cert, nil
+}
@@ -0,0 +1,218 @@
+// Package bshc implements a client for the local API of the Bosch Smart Home
+// Controller (BSHC).
+package bshc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Default timeouts of a client.
+const (
+	DefaultConnectTimeout = 10 * time.Second
+	DefaultReadTimeout    = 30 * time.Second
+)
+
+// DefaultPublicPort is the port of the public API of the BSHC, which does
+// not require a client certificate.
+const DefaultPublicPort = "8446"
+
+// Client talks to the local API of a BSHC using a client certificate that
+// has been registered with the controller. A client keeps its connections
+// alive between requests and is safe for concurrent use.
+type Client struct {
+	host           string
+	publicPort     string
+	baseURL        string
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+	cert           *certificate
+	transport      *http.Transport
+	httpClient     *http.Client
+}
+
+// Option configures a client.
+type Option func(*Client)
+
+// WithConnectTimeout sets the timeout for establishing a connection to the
+// BSHC including the TLS handshake.
+func WithConnectTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithReadTimeout sets the timeout for a request to the BSHC to complete.
+// Long polls may take longer by the time they wait for events.
+func WithReadTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.readTimeout = timeout
+	}
+}
+
+// WithPublicPort sets the port of the public API of the BSHC.
+func WithPublicPort(port string) Option {
+	return func(c *Client) {
+		c.publicPort = port
+	}
+}
+
+// NewClient returns a client for the BSHC reachable at host and port that
+// authenticates with the given client certificate and key files. The key
+// pair is reloaded automatically when the files change on disk.
+func NewClient(host, port, clientCert, clientKey string, skipTLSVerify bool, options ...Option) *Client {
+	c := &Client{
+		host:           host,
+		publicPort:     DefaultPublicPort,
+		baseURL:        fmt.Sprintf("https://%s:%s", host, port),
+		connectTimeout: DefaultConnectTimeout,
+		readTimeout:    DefaultReadTimeout,
+		cert:           &certificate{certFile: clientCert, keyFile: clientKey},
+	}
+	for _, option := range options {
+		option(c)
+	}
+
+	dialer := &net.Dialer{Timeout: c.connectTimeout}
+	c.transport = &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: c.connectTimeout,
+		IdleConnTimeout:     90 * time.Second,
+		MaxIdleConnsPerHost: 4,
+		TLSClientConfig: &tls.Config{
+			GetClientCertificate: c.cert.get,
+			InsecureSkipVerify:   skipTLSVerify,
+		},
+	}
+	c.httpClient = &http.Client{Transport: c.transport}
+	return c
+}
+
+// Devices returns all devices paired with the BSHC.
+func (c *Client) Devices(ctx context.Context) ([]Device, error) {
+	var devices []Device
+	if err := c.get(ctx, "/smarthome/devices", &devices); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// Rooms returns all rooms configured on the BSHC.
+func (c *Client) Rooms(ctx context.Context) ([]Room, error) {
+	var rooms []Room
+	if err := c.get(ctx, "/smarthome/rooms", &rooms); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+// Services returns the services of all devices including their state.
+func (c *Client) Services(ctx context.Context) ([]DeviceService, error) {
+	var services []DeviceService
+	if err := c.get(ctx, "/smarthome/services", &services); err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// ServiceState returns the raw state of a single service of a device. Use
+// json.Unmarshal with one of the state types to decode it.
+func (c *Client) ServiceState(ctx context.Context, deviceID, serviceID string) (json.RawMessage, error) {
+	path := fmt.Sprintf("/smarthome/devices/%s/services/%s/state", url.PathEscape(deviceID), url.PathEscape(serviceID))
+
+	var state json.RawMessage
+	if err := c.get(ctx, path, &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// CloseIdleConnections closes connections to the BSHC that are currently
+// kept alive.
+func (c *Client) CloseIdleConnections() {
+	c.transport.CloseIdleConnections()
+}
+
+// get makes a GET request and decodes the JSON response into out.
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	return c.do(ctx, http.MethodGet, c.baseURL+path, nil, out, c.readTimeout)
+}
+
+// getPublic makes a GET request to the public API and decodes the JSON
+// response into out.
+func (c *Client) getPublic(ctx context.Context, path string, out interface{}) error {
+	publicURL := fmt.Sprintf("https://%s:%s%s", c.host, c.publicPort, path)
+	return c.do(ctx, http.MethodGet, publicURL, nil, out, c.readTimeout)
+}
+
+// post makes a POST request with a JSON body and decodes the JSON response
+// into out. The request may take up to timeout to complete.
+func (c *Client) post(ctx context.Context, path string, in, out interface{}, timeout time.Duration) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	return c.do(ctx, http.MethodPost, c.baseURL+path, body, out, timeout)
+}
+
+// do makes a request to a URL of the BSHC and decodes the JSON response into
+// out.
+func (c *Client) do(ctx context.Context, method, target string, body []byte, out interface{}, timeout time.Duration) error {
+	// Load the client certificate and drop connections using an outdated one
+	reloaded, err := c.cert.reload()
+	if err != nil {
+		return err
+	}
+	if reloaded {
+		c.transport.CloseIdleConnections()
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create %s request for %s: %w", method, target, err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("could not make %s request to %s: %w", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	// Check if response status code is 200
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d for %s", resp.StatusCode, target)
+	}
+
+	// Read response body
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body of %s: %w", target, err)
+	}
+
+	// Parse JSON response
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response of %s: %w", target, err)
+	}
+	return nil
+}
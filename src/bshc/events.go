@@ -0,0 +1,75 @@
+package bshc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EventTypeDeviceServiceData is the type of events reporting a changed
+// service state.
+const EventTypeDeviceServiceData = "DeviceServiceData"
+
+// rpcRequest is a JSON-RPC request to the BSHC.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC response from the BSHC.
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Subscribe subscribes to the event stream of the BSHC and returns the id
+// of the subscription.
+func (c *Client) Subscribe(ctx context.Context) (string, error) {
+	var subscriptionID string
+	if err := c.call(ctx, "RE/subscribe", 0, &subscriptionID, "com/bosch/sh/remote/*", nil); err != nil {
+		return "", err
+	}
+	return subscriptionID, nil
+}
+
+// LongPoll waits up to timeout seconds for events on a subscription. Events
+// of the type EventTypeDeviceServiceData carry the new state of a service.
+func (c *Client) LongPoll(ctx context.Context, subscriptionID string, timeout int) ([]DeviceService, error) {
+	var events []DeviceService
+	if err := c.call(ctx, "RE/longPoll", time.Duration(timeout)*time.Second, &events, subscriptionID, timeout); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Unsubscribe ends a subscription to the event stream of the BSHC.
+func (c *Client) Unsubscribe(ctx context.Context, subscriptionID string) error {
+	var result json.RawMessage
+	return c.call(ctx, "RE/unsubscribe", 0, &result, subscriptionID)
+}
+
+// call calls a JSON-RPC method and decodes its result into out. The method
+// may block on the BSHC for up to wait on top of the read timeout.
+func (c *Client) call(ctx context.Context, method string, wait time.Duration, out interface{}, params ...interface{}) error {
+	var responses []rpcResponse
+	request := []rpcRequest{{JSONRPC: "2.0", Method: method, Params: params}}
+	if err := c.post(ctx, "/remote/json-rpc", request, &responses, c.readTimeout+wait); err != nil {
+		return err
+	}
+
+	if len(responses) == 0 {
+		return fmt.Errorf("empty %s response", method)
+	}
+	if responses[0].Error != nil {
+		return fmt.Errorf("%s failed with code %d: %s", method, responses[0].Error.Code, responses[0].Error.Message)
+	}
+	if err := json.Unmarshal(responses[0].Result, out); err != nil {
+		return fmt.Errorf("failed to unmarshal %s result: %w", method, err)
+	}
+	return nil
+}
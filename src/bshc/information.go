@@ -0,0 +1,47 @@
+package bshc
+
+import "context"
+
+// Software update states of the BSHC.
+const (
+	UpdateNotAvailable = "NO_UPDATE_AVAILABLE"
+	UpdateDownloading  = "DOWNLOADING"
+	UpdateInProgress   = "UPDATE_IN_PROGRESS"
+	UpdateAvailable    = "UPDATE_AVAILABLE"
+)
+
+// BackendConnected is the connectivity state of a BSHC connected to the Bosch
+// backend.
+const BackendConnected = "CONNECTED"
+
+// Information describes the BSHC itself. Older controller software may leave
+// out some of the fields.
+type Information struct {
+	APIVersions         []string            `json:"apiVersions"`
+	SoftwareUpdateState SoftwareUpdateState `json:"softwareUpdateState"`
+	Claimed             bool                `json:"claimed"`
+	CountryCode         string              `json:"countryCode"`
+	MACAddress          string              `json:"macAddress"`
+	IPAddress           string              `json:"shcIpAddress"`
+	Generation          string              `json:"shcGeneration"`
+	BackendConnectivity string              `json:"backendConnectivityState"`
+	FeatureToggles      map[string]bool     `json:"featureToggles"`
+}
+
+// SoftwareUpdateState is the state of the software of the BSHC.
+type SoftwareUpdateState struct {
+	State            string `json:"swUpdateState"`
+	LastResult       string `json:"swUpdateLastResult"`
+	AvailableVersion string `json:"swUpdateAvailableVersion"`
+	InstalledVersion string `json:"swInstalledVersion"`
+}
+
+// Information returns the public information of the BSHC such as its
+// software version and update state. It is served by the public API.
+func (c *Client) Information(ctx context.Context) (*Information, error) {
+	var information Information
+	if err := c.getPublic(ctx, "/smarthome/public/information", &information); err != nil {
+		return nil, err
+	}
+	return &information, nil
+}
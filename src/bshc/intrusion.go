@@ -0,0 +1,62 @@
+package bshc
+
+import "context"
+
+// Arming states of the intrusion detection system.
+const (
+	SystemDisarmed = "SYSTEM_DISARMED"
+	SystemArming   = "SYSTEM_ARMING"
+	SystemArmed    = "SYSTEM_ARMED"
+)
+
+// Alarm states of the intrusion detection system.
+const (
+	IntrusionAlarmOff   = "ALARM_OFF"
+	IntrusionPreAlarm   = "PRE_ALARM"
+	IntrusionAlarmOn    = "ALARM_ON"
+	IntrusionAlarmMuted = "ALARM_MUTED"
+)
+
+// Configuration profiles of the intrusion detection system.
+const (
+	ProfileFullProtection    = "0"
+	ProfilePartialProtection = "1"
+	ProfileCustomProtection  = "2"
+)
+
+// IntrusionSystemState is the state of the intrusion detection system.
+type IntrusionSystemState struct {
+	SystemAvailability struct {
+		Available bool `json:"available"`
+	} `json:"systemAvailability"`
+	ArmingState struct {
+		State                   string `json:"state"`
+		RemainingTimeUntilArmed int    `json:"remainingTimeUntilArmed"`
+	} `json:"armingState"`
+	AlarmState struct {
+		Value     string              `json:"value"`
+		Incidents []IntrusionIncident `json:"incidents"`
+	} `json:"alarmState"`
+	ActiveConfigurationProfile struct {
+		ProfileID string `json:"profileId"`
+	} `json:"activeConfigurationProfile"`
+}
+
+// IntrusionIncident is a trigger of an alarm of the intrusion detection
+// system.
+type IntrusionIncident struct {
+	Type        string `json:"type"`
+	TriggerName string `json:"triggerName"`
+	Location    string `json:"location"`
+	LocationID  string `json:"locationId"`
+	Time        int64  `json:"time"`
+}
+
+// IntrusionSystemState returns the state of the intrusion detection system.
+func (c *Client) IntrusionSystemState(ctx context.Context) (*IntrusionSystemState, error) {
+	var state IntrusionSystemState
+	if err := c.get(ctx, "/smarthome/intrusion/states/system", &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
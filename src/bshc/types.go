@@ -0,0 +1,498 @@
+package bshc
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+)
+
+// Device is a device paired with the BSHC.
+type Device struct {
+	Type             string   `json:"@type"`
+	ID               string   `json:"id"`
+	RoomID           string   `json:"roomId"`
+	Name             string   `json:"name"`
+	DeviceModel      string   `json:"deviceModel"`
+	Manufacturer     string   `json:"manufacturer"`
+	Serial           string   `json:"serial"`
+	Profile          string   `json:"profile"`
+	IconID           string   `json:"iconId"`
+	Status           string   `json:"status"`
+	ParentDeviceID   string   `json:"parentDeviceId"`
+	ChildDeviceIDs   []string `json:"childDeviceIds"`
+	DeviceServiceIDs []string `json:"deviceServiceIds"`
+}
+
+// Values of the status of a device.
+const (
+	DeviceStatusAvailable   = "AVAILABLE"
+	DeviceStatusUnavailable = "UNAVAILABLE"
+	DeviceStatusUndefined   = "UNDEFINED"
+)
+
+// Room is a room configured on the BSHC.
+type Room struct {
+	Type   string `json:"@type"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	IconID string `json:"iconId"`
+}
+
+// DeviceService is a service of a device together with its current state.
+// Events of the type DeviceServiceData from the event stream share this
+// layout.
+type DeviceService struct {
+	Type     string          `json:"@type"`
+	ID       string          `json:"id"`
+	DeviceID string          `json:"deviceId"`
+	Path     string          `json:"path"`
+	State    json.RawMessage `json:"state"`
+	Faults   *Faults         `json:"faults"`
+}
+
+// Faults lists the faults a service reports, e.g. a low battery.
+type Faults struct {
+	Entries []Fault `json:"entries"`
+}
+
+// Fault is a fault reported by a service.
+type Fault struct {
+	Type     string `json:"type"`
+	Category string `json:"category"`
+}
+
+// FaultEntries returns the faults of the service, if any.
+func (s DeviceService) FaultEntries() []Fault {
+	if s.Faults == nil {
+		return nil
+	}
+	return s.Faults.Entries
+}
+
+// Battery states derived from the faults of the BatteryLevel service.
+const (
+	BatteryOK                   = "OK"
+	BatteryLow                  = "LOW_BATTERY"
+	BatteryCriticalLow          = "CRITICAL_LOW"
+	BatteryCriticallyLowBattery = "CRITICALLY_LOW_BATTERY"
+	BatteryNotAvailable         = "NOT_AVAILABLE"
+)
+
+// BatteryState returns the battery state of a BatteryLevel service, which is
+// BatteryOK unless the service reports a fault.
+func (s DeviceService) BatteryState() string {
+	if faults := s.FaultEntries(); len(faults) > 0 {
+		return faults[0].Type
+	}
+	return BatteryOK
+}
+
+// DecodeState decodes the state of the service into v, which is usually a
+// pointer to one of the state types.
+func (s DeviceService) DecodeState(v interface{}) error {
+	return json.Unmarshal(s.State, v)
+}
+
+// TemperatureLevelState is the state of the TemperatureLevel service.
+type TemperatureLevelState struct {
+	Temperature float64 `json:"temperature"`
+}
+
+// Operation modes of the RoomClimateControl service.
+const (
+	OperationModeAutomatic = "AUTOMATIC"
+	OperationModeManual    = "MANUAL"
+	OperationModeOff       = "OFF"
+)
+
+// Room control modes of the RoomClimateControl service.
+const (
+	RoomControlModeHeating = "HEATING"
+	RoomControlModeCooling = "COOLING"
+	RoomControlModeOff     = "OFF"
+)
+
+// RoomClimateControlState is the state of the RoomClimateControl service.
+// Temperatures are reported in °C, the eco and comfort temperatures are nil
+// if the BSHC does not report them.
+type RoomClimateControlState struct {
+	OperationMode                      string           `json:"operationMode"`
+	SetpointTemperature                float64          `json:"setpointTemperature"`
+	SetpointTemperatureForLevelEco     *float64         `json:"setpointTemperatureForLevelEco"`
+	SetpointTemperatureForLevelComfort *float64         `json:"setpointTemperatureForLevelComfort"`
+	Schedule                           *ClimateSchedule `json:"schedule"`
+	RoomControlMode                    string           `json:"roomControlMode"`
+	BoostMode                          bool             `json:"boostMode"`
+	SupportsBoostMode                  bool             `json:"supportsBoostMode"`
+	SummerMode                         bool             `json:"summerMode"`
+	VentilationMode                    bool             `json:"ventilationMode"`
+	Low                                bool             `json:"low"`
+}
+
+// Temperature levels of the switch points of a climate schedule.
+const (
+	TemperatureLevelEco     = "ECO"
+	TemperatureLevelComfort = "COMFORT"
+)
+
+// ClimateSchedule is the weekly schedule of the RoomClimateControl service.
+type ClimateSchedule struct {
+	Profiles []ScheduleProfile `json:"profiles"`
+}
+
+// ScheduleProfile holds the switch points of one day of the week, e.g.
+// MONDAY, sorted by their start time.
+type ScheduleProfile struct {
+	Day          string        `json:"day"`
+	SwitchPoints []SwitchPoint `json:"switchPoints"`
+}
+
+// SwitchPoint switches the room climate control to a temperature level at a
+// time of the day given in minutes after midnight.
+type SwitchPoint struct {
+	StartTimeMinutes int `json:"startTimeMinutes"`
+	Value            struct {
+		TemperatureLevel string `json:"temperatureLevel"`
+	} `json:"value"`
+}
+
+// Current returns the switch point in effect at the given time in the time
+// zone of the BSHC, which may be the last one of a previous day.
+func (s ClimateSchedule) Current(now time.Time, location *time.Location) (SwitchPoint, bool) {
+	now = now.In(location)
+	minutes := now.Hour()*60 + now.Minute()
+	for days := 0; days <= 7; days++ {
+		day := strings.ToUpper(now.AddDate(0, 0, -days).Weekday().String())
+		for _, profile := range s.Profiles {
+			if profile.Day != day {
+				continue
+			}
+			for i := len(profile.SwitchPoints) - 1; i >= 0; i-- {
+				if days > 0 || profile.SwitchPoints[i].StartTimeMinutes <= minutes {
+					return profile.SwitchPoints[i], true
+				}
+			}
+		}
+	}
+	return SwitchPoint{}, false
+}
+
+// HeatingCircuitState is the state of the HeatingCircuit service of a
+// connected Bosch heating system. The setpoint temperature is reported in °C
+// and the operation mode uses the same values as the RoomClimateControl
+// service.
+type HeatingCircuitState struct {
+	OperationMode                     string  `json:"operationMode"`
+	SetpointTemperature               float64 `json:"setpointTemperature"`
+	TemperatureOverrideModeActive     bool    `json:"temperatureOverrideModeActive"`
+	TemperatureOverrideFeatureEnabled bool    `json:"temperatureOverrideFeatureEnabled"`
+	EnergySavingFeatureEnabled        bool    `json:"energySavingFeatureEnabled"`
+}
+
+// HumidityLevelState is the state of the HumidityLevel service.
+type HumidityLevelState struct {
+	Humidity float64 `json:"humidity"`
+}
+
+// ValveTappetState is the state of the ValveTappet service.
+type ValveTappetState struct {
+	Position float64 `json:"position"`
+	Value    string  `json:"value"`
+}
+
+// PowerMeterState is the state of the PowerMeter service. The power
+// consumption is reported in W, the energy consumption in Wh.
+type PowerMeterState struct {
+	PowerConsumption  float64 `json:"powerConsumption"`
+	EnergyConsumption float64 `json:"energyConsumption"`
+}
+
+// Switch states of the PowerSwitch service.
+const (
+	SwitchStateOn  = "ON"
+	SwitchStateOff = "OFF"
+)
+
+// Values of the ShutterContact service.
+const (
+	ContactOpen   = "OPEN"
+	ContactClosed = "CLOSED"
+)
+
+// ShutterContactState is the state of the ShutterContact service of door and
+// window contacts.
+type ShutterContactState struct {
+	Value string `json:"value"`
+}
+
+// Ratings of the AirQualityLevel service.
+const (
+	RatingGood    = "GOOD"
+	RatingMedium  = "MEDIUM"
+	RatingBad     = "BAD"
+	RatingUnknown = "UNKNOWN"
+)
+
+// AirQualityLevelState is the state of the AirQualityLevel service of the
+// Twinguard. The purity is reported in ppm, the temperature in °C and the
+// humidity in %.
+type AirQualityLevelState struct {
+	CombinedRating    string  `json:"combinedRating"`
+	Description       string  `json:"description"`
+	Temperature       float64 `json:"temperature"`
+	TemperatureRating string  `json:"temperatureRating"`
+	Humidity          float64 `json:"humidity"`
+	HumidityRating    string  `json:"humidityRating"`
+	Purity            float64 `json:"purity"`
+	PurityRating      string  `json:"purityRating"`
+}
+
+// Values of the SmokeDetectorCheck service.
+const (
+	SmokeTestNone              = "NONE"
+	SmokeTestRequested         = "SMOKE_TEST_REQUESTED"
+	SmokeTestOK                = "SMOKE_TEST_OK"
+	SmokeTestFailed            = "SMOKE_TEST_FAILED"
+	CommunicationTestRequested = "COMMUNICATION_TEST_REQUESTED"
+	CommunicationTestSent      = "COMMUNICATION_TEST_SENT"
+	CommunicationTestOK        = "COMMUNICATION_TEST_OK"
+)
+
+// SmokeDetectorCheckState is the state of the SmokeDetectorCheck service
+// holding the result of the last self-test.
+type SmokeDetectorCheckState struct {
+	Value string `json:"value"`
+}
+
+// Values of the Alarm service.
+const (
+	AlarmIdleOff   = "IDLE_OFF"
+	AlarmPrimary   = "PRIMARY_ALARM"
+	AlarmSecondary = "SECONDARY_ALARM"
+	AlarmIntrusion = "INTRUSION_ALARM"
+)
+
+// AlarmState is the state of the Alarm service of smoke detectors.
+type AlarmState struct {
+	Value string `json:"value"`
+}
+
+// Values of the SmokeSensitivity service.
+const (
+	SmokeSensitivityLow    = "LOW"
+	SmokeSensitivityMiddle = "MIDDLE"
+	SmokeSensitivityHigh   = "HIGH"
+)
+
+// SmokeSensitivityState is the state of the SmokeSensitivity service.
+type SmokeSensitivityState struct {
+	SmokeSensitivity string `json:"smokeSensitivity"`
+}
+
+// Values of the SurveillanceAlarm service.
+const (
+	SurveillanceAlarmOff   = "ALARM_OFF"
+	SurveillanceAlarmOn    = "ALARM_ON"
+	SurveillanceAlarmMuted = "ALARM_MUTED"
+)
+
+// SurveillanceAlarmState is the state of the SurveillanceAlarm service of the
+// smoke detection system.
+type SurveillanceAlarmState struct {
+	Value string `json:"value"`
+}
+
+// LatestMotionState is the state of the LatestMotion service of motion
+// detectors. LatestMotionDetected is zero if no motion has been detected yet.
+type LatestMotionState struct {
+	LatestMotionDetected time.Time `json:"latestMotionDetected"`
+}
+
+// MultiLevelSensorState is the state of the MultiLevelSensor service.
+// Illuminance is nil for sensors not measuring it.
+type MultiLevelSensorState struct {
+	Illuminance *float64 `json:"illuminance"`
+}
+
+// Operation states of the ShutterControl service.
+const (
+	OperationStopped = "STOPPED"
+	OperationMoving  = "MOVING"
+	OperationOpening = "OPENING"
+	OperationClosing = "CLOSING"
+)
+
+// ShutterControlState is the state of the ShutterControl service of roller
+// shutters and blinds. The level ranges from 0 (closed) to 1 (open).
+type ShutterControlState struct {
+	Level          float64 `json:"level"`
+	OperationState string  `json:"operationState"`
+	Calibrated     bool    `json:"calibrated"`
+}
+
+// Values of the CommunicationQuality service.
+const (
+	QualityGood     = "GOOD"
+	QualityMedium   = "MEDIUM"
+	QualityNormal   = "NORMAL"
+	QualityBad      = "BAD"
+	QualityUnknown  = "UNKNOWN"
+	QualityFetching = "FETCHING"
+)
+
+// CommunicationQualityState is the state of the CommunicationQuality service
+// describing the radio link of a device.
+type CommunicationQualityState struct {
+	Quality string `json:"quality"`
+}
+
+// Values of the WaterLeakageSensor service.
+const (
+	LeakageDetected = "LEAKAGE_DETECTED"
+	NoLeakage       = "NO_LEAKAGE"
+)
+
+// WaterLeakageSensorState is the state of the WaterLeakageSensor service.
+type WaterLeakageSensorState struct {
+	State string `json:"state"`
+}
+
+// Values of the signal settings of the WaterLeakageSensorTilt service.
+const (
+	SignalEnabled  = "ENABLED"
+	SignalDisabled = "DISABLED"
+)
+
+// WaterLeakageSensorTiltState is the state of the WaterLeakageSensorTilt
+// service, which configures how the sensor signals being tilted.
+type WaterLeakageSensorTiltState struct {
+	PushNotificationState string `json:"pushNotificationState"`
+	AcousticSignalState   string `json:"acousticSignalState"`
+}
+
+// WaterLeakageSensorCheckState is the state of the WaterLeakageSensorCheck
+// service holding the result of the last check of the sensor.
+type WaterLeakageSensorCheckState struct {
+	Result string `json:"result"`
+}
+
+// BinarySwitchState is the state of the BinarySwitch service of lights.
+type BinarySwitchState struct {
+	On bool `json:"on"`
+}
+
+// MultiLevelSwitchState is the state of the MultiLevelSwitch service of
+// dimmable lights. The level ranges from 0 to 100.
+type MultiLevelSwitchState struct {
+	Level float64 `json:"level"`
+}
+
+// ColorTemperatureRange is the range of color temperatures a light supports
+// in mired.
+type ColorTemperatureRange struct {
+	MinCt float64 `json:"minCt"`
+	MaxCt float64 `json:"maxCt"`
+}
+
+// HueColorTemperatureState is the state of the HueColorTemperature service.
+// The color temperature is reported in mired.
+type HueColorTemperatureState struct {
+	ColorTemperature      float64               `json:"colorTemperature"`
+	ColorTemperatureRange ColorTemperatureRange `json:"colorTemperatureRange"`
+}
+
+// HSBColorActuatorState is the state of the HSBColorActuator service of
+// colored lights. The color is reported as RGB value.
+type HSBColorActuatorState struct {
+	RGB                   int                   `json:"rgb"`
+	Gamut                 string                `json:"gamut"`
+	ColorTemperatureRange ColorTemperatureRange `json:"colorTemperatureRange"`
+}
+
+// HueSaturation returns the hue in degrees from 0 to 360 and the saturation
+// from 0 to 1 of the color.
+func (s HSBColorActuatorState) HueSaturation() (float64, float64) {
+	r := float64((s.RGB>>16)&0xff) / 255
+	g := float64((s.RGB>>8)&0xff) / 255
+	b := float64(s.RGB&0xff) / 255
+
+	highest := math.Max(r, math.Max(g, b))
+	lowest := math.Min(r, math.Min(g, b))
+	delta := highest - lowest
+	if delta == 0 {
+		return 0, 0
+	}
+
+	var hue float64
+	switch highest {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+	return hue, delta / highest
+}
+
+// Values of the child lock of the Thermostat service.
+const (
+	ChildLockOn  = "ON"
+	ChildLockOff = "OFF"
+)
+
+// ThermostatState is the state of the Thermostat service.
+type ThermostatState struct {
+	ChildLock string `json:"childLock"`
+}
+
+// TemperatureOffsetState is the state of the TemperatureOffset service of
+// thermostats. All values are reported in °C.
+type TemperatureOffsetState struct {
+	Offset    float64 `json:"offset"`
+	StepSize  float64 `json:"stepSize"`
+	MinOffset float64 `json:"minOffset"`
+	MaxOffset float64 `json:"maxOffset"`
+}
+
+// DisplayConfigurationState is the state of the DisplayConfiguration service
+// of thermostats. The display on time is reported in seconds.
+type DisplayConfigurationState struct {
+	DisplayBrightness float64 `json:"displayBrightness"`
+	DisplayOnTime     float64 `json:"displayOnTime"`
+}
+
+// Values of the DisplayDirection service.
+const (
+	DisplayDirectionNormal   = "NORMAL"
+	DisplayDirectionReversed = "REVERSED"
+)
+
+// DisplayDirectionState is the state of the DisplayDirection service of
+// thermostats.
+type DisplayDirectionState struct {
+	Direction string `json:"direction"`
+}
+
+// Values of the DisplayedTemperatureConfiguration service.
+const (
+	DisplayedTemperatureSetpoint = "SETPOINT"
+	DisplayedTemperatureMeasured = "MEASURED"
+)
+
+// DisplayedTemperatureConfigurationState is the state of the
+// DisplayedTemperatureConfiguration service of thermostats.
+type DisplayedTemperatureConfigurationState struct {
+	DisplayedTemperature string `json:"displayedTemperature"`
+}
+
+// PowerSwitchState is the state of the PowerSwitch service.
+type PowerSwitchState struct {
+	SwitchState           string `json:"switchState"`
+	AutomaticPowerOffTime int    `json:"automaticPowerOffTime"`
+}
@@ -0,0 +1,79 @@
+package bshc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestHueSaturation(t *testing.T) {
+	tests := []struct {
+		name       string
+		rgb        int
+		hue        float64
+		saturation float64
+	}{
+		{name: "red", rgb: 0xff0000, hue: 0, saturation: 1},
+		{name: "green", rgb: 0x00ff00, hue: 120, saturation: 1},
+		{name: "blue", rgb: 0x0000ff, hue: 240, saturation: 1},
+		{name: "magenta", rgb: 0xff00ff, hue: 300, saturation: 1},
+		{name: "pale red", rgb: 0xff8080, hue: 0, saturation: 127.0 / 255},
+		{name: "white", rgb: 0xffffff, hue: 0, saturation: 0},
+		{name: "black", rgb: 0x000000, hue: 0, saturation: 0},
+		{name: "negative green", rgb: -16711936, hue: 120, saturation: 1},
+		{name: "negative red", rgb: -65536, hue: 0, saturation: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hue, saturation := HSBColorActuatorState{RGB: tt.rgb}.HueSaturation()
+			if math.Abs(hue-tt.hue) > 1e-9 || math.Abs(saturation-tt.saturation) > 1e-9 {
+				t.Errorf("HueSaturation() = %v, %v, want %v, %v", hue, saturation, tt.hue, tt.saturation)
+			}
+		})
+	}
+}
+
+func TestClimateScheduleCurrent(t *testing.T) {
+	cest := time.FixedZone("CEST", 2*60*60)
+
+	point := func(minutes int, level string) SwitchPoint {
+		var p SwitchPoint
+		p.StartTimeMinutes = minutes
+		p.Value.TemperatureLevel = level
+		return p
+	}
+	week := ClimateSchedule{Profiles: []ScheduleProfile{
+		{Day: "MONDAY", SwitchPoints: []SwitchPoint{point(360, TemperatureLevelComfort), point(1320, TemperatureLevelEco)}},
+		{Day: "TUESDAY", SwitchPoints: []SwitchPoint{point(480, TemperatureLevelComfort)}},
+	}}
+	monday := ClimateSchedule{Profiles: []ScheduleProfile{
+		{Day: "MONDAY", SwitchPoints: []SwitchPoint{point(360, TemperatureLevelComfort)}},
+	}}
+
+	tests := []struct {
+		name     string
+		schedule ClimateSchedule
+		now      time.Time
+		location *time.Location
+		want     SwitchPoint
+		ok       bool
+	}{
+		{name: "same day", schedule: week, now: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC), location: time.UTC, want: point(360, TemperatureLevelComfort), ok: true},
+		{name: "last point of the day", schedule: week, now: time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC), location: time.UTC, want: point(1320, TemperatureLevelEco), ok: true},
+		{name: "wrap to previous day", schedule: week, now: time.Date(2026, 10, 20, 5, 0, 0, 0, time.UTC), location: time.UTC, want: point(1320, TemperatureLevelEco), ok: true},
+		{name: "wrap over days without points", schedule: week, now: time.Date(2026, 10, 22, 12, 0, 0, 0, time.UTC), location: time.UTC, want: point(480, TemperatureLevelComfort), ok: true},
+		{name: "wrap to the same day a week ago", schedule: monday, now: time.Date(2026, 10, 19, 5, 0, 0, 0, time.UTC), location: time.UTC, want: point(360, TemperatureLevelComfort), ok: true},
+		{name: "time zone of the BSHC", schedule: week, now: time.Date(2026, 10, 19, 5, 30, 0, 0, time.UTC), location: cest, want: point(360, TemperatureLevelComfort), ok: true},
+		{name: "empty profiles", schedule: ClimateSchedule{}, now: time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC), location: time.UTC, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.schedule.Current(tt.now, tt.location)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("Current() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
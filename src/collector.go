@@ -0,0 +1,1102 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
+)
+
+// Labels of all device metrics
+var deviceLabels = []string{"device_id", "device_name", "room_name"}
+
+// Labels of all room metrics
+var roomLabels = []string{"room_id", "room_name"}
+
+// Labels of all heating circuit metrics
+var heatingCircuitLabels = []string{"device_id", "heating_circuit"}
+
+// Numeric values of air quality ratings, higher is worse
+var ratingValues = map[string]float64{
+	bshc.RatingGood:   0,
+	bshc.RatingMedium: 1,
+	bshc.RatingBad:    2,
+}
+
+// Possible air quality ratings
+var ratingStates = []string{bshc.RatingGood, bshc.RatingMedium, bshc.RatingBad}
+
+// Possible results of smoke detector self-tests
+var smokeTestStates = []string{
+	bshc.SmokeTestNone,
+	bshc.SmokeTestRequested,
+	bshc.SmokeTestOK,
+	bshc.SmokeTestFailed,
+	bshc.CommunicationTestRequested,
+	bshc.CommunicationTestSent,
+	bshc.CommunicationTestOK,
+}
+
+// Possible alarm states of smoke detectors
+var alarmStates = []string{bshc.AlarmIdleOff, bshc.AlarmPrimary, bshc.AlarmSecondary, bshc.AlarmIntrusion}
+
+// Possible smoke sensitivities of smoke detectors
+var smokeSensitivityStates = []string{bshc.SmokeSensitivityLow, bshc.SmokeSensitivityMiddle, bshc.SmokeSensitivityHigh}
+
+// Possible operation states of shutters
+var operationStates = []string{bshc.OperationStopped, bshc.OperationMoving, bshc.OperationOpening, bshc.OperationClosing}
+
+// Possible battery states
+var batteryStates = []string{
+	bshc.BatteryOK,
+	bshc.BatteryLow,
+	bshc.BatteryCriticalLow,
+	bshc.BatteryCriticallyLowBattery,
+	bshc.BatteryNotAvailable,
+}
+
+// Possible communication qualities
+var qualityStates = []string{
+	bshc.QualityGood,
+	bshc.QualityMedium,
+	bshc.QualityNormal,
+	bshc.QualityBad,
+	bshc.QualityUnknown,
+	bshc.QualityFetching,
+}
+
+// Possible operation modes of the room climate control
+var operationModeStates = []string{bshc.OperationModeAutomatic, bshc.OperationModeManual, bshc.OperationModeOff}
+
+// Possible room control modes of the room climate control
+var roomControlModeStates = []string{bshc.RoomControlModeHeating, bshc.RoomControlModeCooling, bshc.RoomControlModeOff}
+
+// Possible temperature levels of the schedule of the room climate control
+var scheduleLevelStates = []string{bshc.TemperatureLevelEco, bshc.TemperatureLevelComfort}
+
+// Possible arming states of the intrusion detection system
+var armingStates = []string{bshc.SystemDisarmed, bshc.SystemArming, bshc.SystemArmed}
+
+// Possible alarm states of the intrusion detection system
+var intrusionAlarmStates = []string{bshc.IntrusionAlarmOff, bshc.IntrusionPreAlarm, bshc.IntrusionAlarmOn, bshc.IntrusionAlarmMuted}
+
+// Names of the configuration profiles of the intrusion detection system
+var profileNames = map[string]string{
+	bshc.ProfileFullProtection:    "full",
+	bshc.ProfilePartialProtection: "partial",
+	bshc.ProfileCustomProtection:  "custom",
+}
+
+// Possible configuration profiles of the intrusion detection system
+var profileStates = []string{"full", "partial", "custom"}
+
+// Possible display directions of thermostats
+var displayDirectionStates = []string{bshc.DisplayDirectionNormal, bshc.DisplayDirectionReversed}
+
+// Possible temperatures displayed by thermostats
+var displayedTemperatureStates = []string{bshc.DisplayedTemperatureSetpoint, bshc.DisplayedTemperatureMeasured}
+
+// Possible software update states of the BSHC
+var updateStates = []string{bshc.UpdateNotAvailable, bshc.UpdateDownloading, bshc.UpdateInProgress, bshc.UpdateAvailable}
+
+// Possible alarm states of the smoke detection system
+var surveillanceAlarmStates = []string{bshc.SurveillanceAlarmOff, bshc.SurveillanceAlarmOn, bshc.SurveillanceAlarmMuted}
+
+// Prometheus metric descriptions
+var (
+	temperatureDesc = prometheus.NewDesc(
+		"temperature_level",
+		"Temperature level of the devices",
+		deviceLabels, nil,
+	)
+	setpointTemperatureDesc = prometheus.NewDesc(
+		"setpoint_temperature_level",
+		"Desired temperature level of the devices",
+		deviceLabels, nil,
+	)
+	humidityDesc = prometheus.NewDesc(
+		"humidity_level",
+		"Humidity level of the devices",
+		deviceLabels, nil,
+	)
+	valveTappetDesc = prometheus.NewDesc(
+		"valve_tappet",
+		"Valve tappet of the devices",
+		deviceLabels, nil,
+	)
+	powerConsumptionDesc = prometheus.NewDesc(
+		"bshc_power_consumption_watts",
+		"Current power consumption of the devices in W",
+		deviceLabels, nil,
+	)
+	energyConsumptionDesc = prometheus.NewDesc(
+		"bshc_energy_consumption_watt_hours_total",
+		"Cumulative energy consumption of the devices in Wh",
+		deviceLabels, nil,
+	)
+	powerSwitchDesc = prometheus.NewDesc(
+		"bshc_power_switch_on",
+		"Whether the power switch of the devices is on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	shutterContactDesc = prometheus.NewDesc(
+		"bshc_shutter_contact_open",
+		"Whether the door or window contact is open (1) or closed (0)",
+		deviceLabels, nil,
+	)
+	shutterContactChangeDesc = prometheus.NewDesc(
+		"bshc_shutter_contact_last_change_timestamp_seconds",
+		"Time of the last state change of the door or window contact observed by the exporter",
+		deviceLabels, nil,
+	)
+	roomInfoDesc = prometheus.NewDesc(
+		"bshc_room_info",
+		"Information about the rooms, always 1",
+		withLabels(roomLabels, "icon_id"), nil,
+	)
+	roomDevicesDesc = prometheus.NewDesc(
+		"bshc_room_devices",
+		"Number of devices in the room",
+		roomLabels, nil,
+	)
+	roomTemperatureAverageDesc = prometheus.NewDesc(
+		"bshc_room_temperature_average_celsius",
+		"Average temperature measured by the devices in the room in °C",
+		roomLabels, nil,
+	)
+	roomTemperatureMinDesc = prometheus.NewDesc(
+		"bshc_room_temperature_min_celsius",
+		"Lowest temperature measured by the devices in the room in °C",
+		roomLabels, nil,
+	)
+	roomTemperatureMaxDesc = prometheus.NewDesc(
+		"bshc_room_temperature_max_celsius",
+		"Highest temperature measured by the devices in the room in °C",
+		roomLabels, nil,
+	)
+	roomHumidityAverageDesc = prometheus.NewDesc(
+		"bshc_room_humidity_average_percent",
+		"Average humidity measured by the devices in the room in percent",
+		roomLabels, nil,
+	)
+	roomValveAverageDesc = prometheus.NewDesc(
+		"bshc_room_valve_position_average_percent",
+		"Average valve position of the thermostats in the room in percent",
+		roomLabels, nil,
+	)
+	roomOpenWindowsDesc = prometheus.NewDesc(
+		"bshc_room_open_windows",
+		"Number of open window contacts in the room",
+		roomLabels, nil,
+	)
+	airPurityDesc = prometheus.NewDesc(
+		"bshc_air_quality_purity_ppm",
+		"Air purity (CO2 equivalent) measured by the devices in ppm",
+		deviceLabels, nil,
+	)
+	airTemperatureDesc = prometheus.NewDesc(
+		"bshc_air_quality_temperature_celsius",
+		"Temperature measured by the air quality sensors in °C",
+		deviceLabels, nil,
+	)
+	airHumidityDesc = prometheus.NewDesc(
+		"bshc_air_quality_humidity_percent",
+		"Humidity measured by the air quality sensors in %",
+		deviceLabels, nil,
+	)
+	airRatingDesc = prometheus.NewDesc(
+		"bshc_air_quality_rating",
+		"Air quality rating of the devices (0 = GOOD, 1 = MEDIUM, 2 = BAD)",
+		withLabels(deviceLabels, "rating"), nil,
+	)
+	airRatingStateDesc = prometheus.NewDesc(
+		"bshc_air_quality_rating_state",
+		"Air quality rating of the devices, 1 for the current state",
+		withLabels(deviceLabels, "rating", "state"), nil,
+	)
+	smokeTestStateDesc = prometheus.NewDesc(
+		"bshc_smoke_detector_check_state",
+		"Result of the last self-test of the smoke detectors, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	smokeTestPassedDesc = prometheus.NewDesc(
+		"bshc_smoke_detector_last_test_ok_timestamp_seconds",
+		"Time the smoke detectors last reported a successful self-test observed by the exporter",
+		deviceLabels, nil,
+	)
+	alarmStateDesc = prometheus.NewDesc(
+		"bshc_alarm_state",
+		"Alarm state of the smoke detectors, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	alarmActiveDesc = prometheus.NewDesc(
+		"bshc_alarm_active",
+		"Whether an alarm of the smoke detectors is active (1) or not (0)",
+		deviceLabels, nil,
+	)
+	smokeSensitivityDesc = prometheus.NewDesc(
+		"bshc_smoke_sensitivity_state",
+		"Smoke sensitivity of the smoke detectors, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	surveillanceAlarmDesc = prometheus.NewDesc(
+		"bshc_smoke_detection_system_alarm_state",
+		"Alarm state of the smoke detection system, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	motionDetectedDesc = prometheus.NewDesc(
+		"bshc_motion_last_detected_timestamp_seconds",
+		"Time the motion detectors last detected a motion",
+		deviceLabels, nil,
+	)
+	motionEventsDesc = prometheus.NewDesc(
+		"bshc_motion_events_total",
+		"Number of motions detected by the motion detectors since the exporter started",
+		deviceLabels, nil,
+	)
+	illuminanceDesc = prometheus.NewDesc(
+		"bshc_illuminance_level",
+		"Illuminance measured by the devices",
+		deviceLabels, nil,
+	)
+	shutterLevelDesc = prometheus.NewDesc(
+		"bshc_shutter_level_ratio",
+		"Level of the shutters from 0 (closed) to 1 (open)",
+		deviceLabels, nil,
+	)
+	shutterOperationDesc = prometheus.NewDesc(
+		"bshc_shutter_operation_state",
+		"Operation state of the shutters, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	shutterCalibratedDesc = prometheus.NewDesc(
+		"bshc_shutter_calibrated",
+		"Whether the shutters are calibrated (1) or not (0)",
+		deviceLabels, nil,
+	)
+	batteryStateDesc = prometheus.NewDesc(
+		"bshc_battery_state",
+		"Battery state of the battery-powered devices, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	deviceFaultDesc = prometheus.NewDesc(
+		"bshc_device_fault",
+		"Faults currently reported by the services of the devices",
+		withLabels(deviceLabels, "service_id", "fault", "category"), nil,
+	)
+	deviceAvailableDesc = prometheus.NewDesc(
+		"bshc_device_available",
+		"Whether the devices are available (1) or not (0)",
+		deviceLabels, nil,
+	)
+	deviceInfoDesc = prometheus.NewDesc(
+		"bshc_device_info",
+		"Information about the devices, always 1",
+		withLabels(deviceLabels, "device_model", "manufacturer", "serial", "profile", "parent_device_id", "icon_id", "services"), nil,
+	)
+	commQualityDesc = prometheus.NewDesc(
+		"bshc_communication_quality_state",
+		"Quality of the radio link of the devices, 1 for the current state",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	ecoTemperatureDesc = prometheus.NewDesc(
+		"bshc_climate_control_eco_temperature_celsius",
+		"Setpoint temperature of the eco level of the room climate control in °C",
+		deviceLabels, nil,
+	)
+	comfortTemperatureDesc = prometheus.NewDesc(
+		"bshc_climate_control_comfort_temperature_celsius",
+		"Setpoint temperature of the comfort level of the room climate control in °C",
+		deviceLabels, nil,
+	)
+	operationModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_operation_mode",
+		"Operation mode of the room climate control, 1 for the current mode",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	roomControlModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_room_control_mode",
+		"Room control mode of the room climate control, 1 for the current mode",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	boostModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_boost_mode",
+		"Whether the boost mode of the room climate control is on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	supportsBoostModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_supports_boost_mode",
+		"Whether the room climate control supports the boost mode (1) or not (0)",
+		deviceLabels, nil,
+	)
+	summerModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_summer_mode",
+		"Whether the summer mode of the room climate control is on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	ventilationModeDesc = prometheus.NewDesc(
+		"bshc_climate_control_ventilation_mode",
+		"Whether the ventilation mode of the room climate control is on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	lowDesc = prometheus.NewDesc(
+		"bshc_climate_control_low",
+		"Whether the room climate control is set to the eco level (1) or not (0)",
+		deviceLabels, nil,
+	)
+	scheduleLevelDesc = prometheus.NewDesc(
+		"bshc_climate_control_schedule_level",
+		"Temperature level of the current switch point of the room climate control schedule, 1 for the current level",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	scheduleSwitchPointDesc = prometheus.NewDesc(
+		"bshc_climate_control_schedule_switch_point_minutes",
+		"Start of the current switch point of the room climate control schedule in minutes after midnight",
+		deviceLabels, nil,
+	)
+	circuitSetpointDesc = prometheus.NewDesc(
+		"bshc_heating_circuit_setpoint_temperature_celsius",
+		"Setpoint temperature of the heating circuits in °C",
+		heatingCircuitLabels, nil,
+	)
+	circuitOperationModeDesc = prometheus.NewDesc(
+		"bshc_heating_circuit_operation_mode",
+		"Operation mode of the heating circuits, 1 for the current mode",
+		withLabels(heatingCircuitLabels, "state"), nil,
+	)
+	circuitOverrideActiveDesc = prometheus.NewDesc(
+		"bshc_heating_circuit_temperature_override_active",
+		"Whether the temperature of the heating circuits is overridden (1) or not (0)",
+		heatingCircuitLabels, nil,
+	)
+	circuitOverrideEnabledDesc = prometheus.NewDesc(
+		"bshc_heating_circuit_temperature_override_enabled",
+		"Whether the temperature override feature of the heating circuits is enabled (1) or not (0)",
+		heatingCircuitLabels, nil,
+	)
+	circuitEnergySavingDesc = prometheus.NewDesc(
+		"bshc_heating_circuit_energy_saving_enabled",
+		"Whether the energy saving feature of the heating circuits is enabled (1) or not (0)",
+		heatingCircuitLabels, nil,
+	)
+	leakageDesc = prometheus.NewDesc(
+		"bshc_water_leakage_detected",
+		"Whether the water leakage sensors detected a leak (1) or not (0)",
+		deviceLabels, nil,
+	)
+	leakageTiltSignalDesc = prometheus.NewDesc(
+		"bshc_water_leakage_tilt_signal_enabled",
+		"Whether the water leakage sensors signal being tilted (1) or not (0)",
+		withLabels(deviceLabels, "signal"), nil,
+	)
+	leakageCheckDesc = prometheus.NewDesc(
+		"bshc_water_leakage_check_result",
+		"Result of the last check of the water leakage sensors, 1 for the current result",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	lightOnDesc = prometheus.NewDesc(
+		"bshc_light_on",
+		"Whether the lights are on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	lightLevelDesc = prometheus.NewDesc(
+		"bshc_light_brightness_percent",
+		"Brightness of the dimmable lights in %",
+		deviceLabels, nil,
+	)
+	lightColorTemperatureDesc = prometheus.NewDesc(
+		"bshc_light_color_temperature_mired",
+		"Color temperature of the lights in mired",
+		deviceLabels, nil,
+	)
+	lightHueDesc = prometheus.NewDesc(
+		"bshc_light_hue_degrees",
+		"Hue of the colored lights in degrees",
+		deviceLabels, nil,
+	)
+	lightSaturationDesc = prometheus.NewDesc(
+		"bshc_light_saturation_ratio",
+		"Saturation of the colored lights from 0 to 1",
+		deviceLabels, nil,
+	)
+	childLockDesc = prometheus.NewDesc(
+		"bshc_thermostat_child_lock",
+		"Whether the child lock of the thermostats is on (1) or off (0)",
+		deviceLabels, nil,
+	)
+	temperatureOffsetDesc = prometheus.NewDesc(
+		"bshc_thermostat_temperature_offset_celsius",
+		"Temperature offset of the thermostats in °C",
+		deviceLabels, nil,
+	)
+	temperatureOffsetStepDesc = prometheus.NewDesc(
+		"bshc_thermostat_temperature_offset_step_celsius",
+		"Step size of the temperature offset of the thermostats in °C",
+		deviceLabels, nil,
+	)
+	temperatureOffsetMinDesc = prometheus.NewDesc(
+		"bshc_thermostat_temperature_offset_min_celsius",
+		"Minimum temperature offset of the thermostats in °C",
+		deviceLabels, nil,
+	)
+	temperatureOffsetMaxDesc = prometheus.NewDesc(
+		"bshc_thermostat_temperature_offset_max_celsius",
+		"Maximum temperature offset of the thermostats in °C",
+		deviceLabels, nil,
+	)
+	displayBrightnessDesc = prometheus.NewDesc(
+		"bshc_thermostat_display_brightness",
+		"Display brightness of the thermostats",
+		deviceLabels, nil,
+	)
+	displayOnTimeDesc = prometheus.NewDesc(
+		"bshc_thermostat_display_on_time_seconds",
+		"Time the display of the thermostats stays on in seconds",
+		deviceLabels, nil,
+	)
+	displayDirectionDesc = prometheus.NewDesc(
+		"bshc_thermostat_display_direction",
+		"Display direction of the thermostats, 1 for the current direction",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	displayedTemperatureDesc = prometheus.NewDesc(
+		"bshc_thermostat_displayed_temperature",
+		"Temperature shown on the display of the thermostats, 1 for the current setting",
+		withLabels(deviceLabels, "state"), nil,
+	)
+	intrusionAvailableDesc = prometheus.NewDesc(
+		"bshc_intrusion_system_available",
+		"Whether the intrusion detection system is available (1) or not (0)",
+		nil, nil,
+	)
+	intrusionArmingDesc = prometheus.NewDesc(
+		"bshc_intrusion_arming_state",
+		"Arming state of the intrusion detection system, 1 for the current state",
+		[]string{"state"}, nil,
+	)
+	intrusionAlarmDesc = prometheus.NewDesc(
+		"bshc_intrusion_alarm_state",
+		"Alarm state of the intrusion detection system, 1 for the current state",
+		[]string{"state"}, nil,
+	)
+	intrusionProfileDesc = prometheus.NewDesc(
+		"bshc_intrusion_active_profile",
+		"Active configuration profile of the intrusion detection system, 1 for the current profile",
+		[]string{"profile"}, nil,
+	)
+	intrusionIncidentsDesc = prometheus.NewDesc(
+		"bshc_intrusion_alarm_incidents",
+		"Number of triggers of the current alarm of the intrusion detection system",
+		nil, nil,
+	)
+	intrusionAlarmsDesc = prometheus.NewDesc(
+		"bshc_intrusion_alarm_activations_total",
+		"Number of alarms of the intrusion detection system observed since the exporter started",
+		nil, nil,
+	)
+	intrusionSuccessDesc = prometheus.NewDesc(
+		"bshc_intrusion_last_success_timestamp_seconds",
+		"Time the state of the intrusion detection system was last fetched successfully",
+		nil, nil,
+	)
+	controllerInfoDesc = prometheus.NewDesc(
+		"bshc_controller_info",
+		"Information about the BSHC, always 1",
+		[]string{"version", "generation", "api_versions", "ip_address", "mac_address", "country_code"}, nil,
+	)
+	controllerUpdateStateDesc = prometheus.NewDesc(
+		"bshc_controller_update_state",
+		"Software update state of the BSHC, 1 for the current state",
+		[]string{"state"}, nil,
+	)
+	controllerUpdateAvailableDesc = prometheus.NewDesc(
+		"bshc_controller_update_available",
+		"Whether a software update is available for the BSHC (1) or not (0)",
+		[]string{"available_version"}, nil,
+	)
+	controllerCloudConnectedDesc = prometheus.NewDesc(
+		"bshc_controller_cloud_connected",
+		"Whether the BSHC is connected to the Bosch backend (1) or not (0)",
+		nil, nil,
+	)
+	controllerSuccessDesc = prometheus.NewDesc(
+		"bshc_controller_last_success_timestamp_seconds",
+		"Time the information about the BSHC was last fetched successfully",
+		nil, nil,
+	)
+	controllerFeatureDesc = prometheus.NewDesc(
+		"bshc_controller_feature_enabled",
+		"Whether a feature toggle of the BSHC is enabled (1) or not (0)",
+		[]string{"feature"}, nil,
+	)
+)
+
+// Collector publishing exactly the series of the current snapshot, so series
+// of deleted, renamed or moved devices vanish with the next poll
+type snapshotCollector struct{}
+
+func (snapshotCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- temperatureDesc
+	ch <- setpointTemperatureDesc
+	ch <- humidityDesc
+	ch <- valveTappetDesc
+	ch <- powerConsumptionDesc
+	ch <- energyConsumptionDesc
+	ch <- powerSwitchDesc
+	ch <- shutterContactDesc
+	ch <- shutterContactChangeDesc
+	ch <- roomOpenWindowsDesc
+	ch <- roomInfoDesc
+	ch <- roomDevicesDesc
+	ch <- roomTemperatureAverageDesc
+	ch <- roomTemperatureMinDesc
+	ch <- roomTemperatureMaxDesc
+	ch <- roomHumidityAverageDesc
+	ch <- roomValveAverageDesc
+	ch <- airPurityDesc
+	ch <- airTemperatureDesc
+	ch <- airHumidityDesc
+	ch <- airRatingDesc
+	ch <- airRatingStateDesc
+	ch <- smokeTestStateDesc
+	ch <- smokeTestPassedDesc
+	ch <- alarmStateDesc
+	ch <- alarmActiveDesc
+	ch <- smokeSensitivityDesc
+	ch <- surveillanceAlarmDesc
+	ch <- motionDetectedDesc
+	ch <- motionEventsDesc
+	ch <- illuminanceDesc
+	ch <- shutterLevelDesc
+	ch <- shutterOperationDesc
+	ch <- shutterCalibratedDesc
+	ch <- batteryStateDesc
+	ch <- deviceFaultDesc
+	ch <- deviceAvailableDesc
+	ch <- deviceInfoDesc
+	ch <- commQualityDesc
+	ch <- ecoTemperatureDesc
+	ch <- comfortTemperatureDesc
+	ch <- operationModeDesc
+	ch <- roomControlModeDesc
+	ch <- boostModeDesc
+	ch <- supportsBoostModeDesc
+	ch <- summerModeDesc
+	ch <- ventilationModeDesc
+	ch <- lowDesc
+	ch <- scheduleLevelDesc
+	ch <- scheduleSwitchPointDesc
+	ch <- circuitSetpointDesc
+	ch <- circuitOperationModeDesc
+	ch <- circuitOverrideActiveDesc
+	ch <- circuitOverrideEnabledDesc
+	ch <- circuitEnergySavingDesc
+	ch <- leakageDesc
+	ch <- leakageTiltSignalDesc
+	ch <- leakageCheckDesc
+	ch <- lightOnDesc
+	ch <- lightLevelDesc
+	ch <- lightColorTemperatureDesc
+	ch <- lightHueDesc
+	ch <- lightSaturationDesc
+	ch <- childLockDesc
+	ch <- temperatureOffsetDesc
+	ch <- temperatureOffsetStepDesc
+	ch <- temperatureOffsetMinDesc
+	ch <- temperatureOffsetMaxDesc
+	ch <- displayBrightnessDesc
+	ch <- displayOnTimeDesc
+	ch <- displayDirectionDesc
+	ch <- displayedTemperatureDesc
+	ch <- intrusionAvailableDesc
+	ch <- intrusionArmingDesc
+	ch <- intrusionAlarmDesc
+	ch <- intrusionProfileDesc
+	ch <- intrusionIncidentsDesc
+	ch <- intrusionAlarmsDesc
+	ch <- intrusionSuccessDesc
+	ch <- controllerInfoDesc
+	ch <- controllerUpdateStateDesc
+	ch <- controllerUpdateAvailableDesc
+	ch <- controllerCloudConnectedDesc
+	ch <- controllerFeatureDesc
+	ch <- controllerSuccessDesc
+}
+
+func (snapshotCollector) Collect(ch chan<- prometheus.Metric) {
+	openWindows := make(map[string]*roomAggregate)
+	temperatures := make(map[string]*roomAggregate)
+	humidities := make(map[string]*roomAggregate)
+	valves := make(map[string]*roomAggregate)
+
+	if c.SERVICES.DeviceAvailability {
+		for _, placed := range topo.list() {
+			available := placed.device.Status == bshc.DeviceStatusAvailable
+			ch <- prometheus.MustNewConstMetric(deviceAvailableDesc, prometheus.GaugeValue, boolToFloat(available), placed.device.ID, placed.device.Name, placed.room.Name)
+		}
+	}
+
+	if c.SERVICES.DeviceInfo {
+		for _, placed := range topo.list() {
+			collectDeviceInfo(ch, placed)
+		}
+	}
+
+	for _, service := range current.get() {
+		device, room, ok := topo.lookup(service.DeviceID)
+		if !ok {
+			continue
+		}
+		labels := []string{service.DeviceID, device.Name, room.Name}
+
+		if c.SERVICES.DeviceFaults {
+			seen := make(map[bshc.Fault]bool)
+			for _, fault := range service.FaultEntries() {
+				if seen[fault] {
+					continue
+				}
+				seen[fault] = true
+				ch <- prometheus.MustNewConstMetric(deviceFaultDesc, prometheus.GaugeValue, 1, withLabels(labels, service.ID, fault.Type, fault.Category)...)
+			}
+		}
+
+		switch service.ID {
+		case "TemperatureLevel":
+			var state bshc.TemperatureLevelState
+			if c.SERVICES.TemperatureLevel && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(temperatureDesc, prometheus.GaugeValue, state.Temperature, labels...)
+				aggregate(temperatures, device, room, state.Temperature)
+			}
+
+		case "RoomClimateControl":
+			var state bshc.RoomClimateControlState
+			if (c.SERVICES.TemperatureLevel || c.SERVICES.ClimateControl) && decodeState(service, &state) {
+				// The setpoint used to be exported with temperature_level only
+				ch <- prometheus.MustNewConstMetric(setpointTemperatureDesc, prometheus.GaugeValue, state.SetpointTemperature, labels...)
+				if c.SERVICES.ClimateControl {
+					collectClimateControl(ch, state, labels)
+				}
+			}
+
+		case "HumidityLevel":
+			var state bshc.HumidityLevelState
+			if c.SERVICES.HumidityLevel && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(humidityDesc, prometheus.GaugeValue, state.Humidity, labels...)
+				aggregate(humidities, device, room, state.Humidity)
+			}
+
+		case "ValveTappet":
+			var state bshc.ValveTappetState
+			if c.SERVICES.ValveTappet && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(valveTappetDesc, prometheus.GaugeValue, state.Position, labels...)
+				aggregate(valves, device, room, state.Position)
+			}
+
+		case "PowerMeter":
+			var state bshc.PowerMeterState
+			if c.SERVICES.PowerMeter && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(powerConsumptionDesc, prometheus.GaugeValue, state.PowerConsumption, labels...)
+				ch <- prometheus.MustNewConstMetric(energyConsumptionDesc, prometheus.CounterValue, state.EnergyConsumption, labels...)
+			}
+
+		case "PowerSwitch":
+			var state bshc.PowerSwitchState
+			if c.SERVICES.PowerSwitch && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(powerSwitchDesc, prometheus.GaugeValue, boolToFloat(state.SwitchState == bshc.SwitchStateOn), labels...)
+			}
+
+		case "ShutterContact":
+			var state bshc.ShutterContactState
+			if c.SERVICES.ShutterContact && decodeState(service, &state) {
+				open := state.Value == bshc.ContactOpen
+				ch <- prometheus.MustNewConstMetric(shutterContactDesc, prometheus.GaugeValue, boolToFloat(open), labels...)
+				if changed, ok := hist.contactChanged(service.DeviceID); ok {
+					ch <- prometheus.MustNewConstMetric(shutterContactChangeDesc, prometheus.GaugeValue, float64(changed.Unix()), labels...)
+				}
+
+				// Count every contact not configured as a door as a window
+				if !strings.HasSuffix(device.Profile, "DOOR") {
+					aggregate(openWindows, device, room, boolToFloat(open))
+				}
+			}
+
+		case "AirQualityLevel":
+			var state bshc.AirQualityLevelState
+			if c.SERVICES.AirQuality && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(airPurityDesc, prometheus.GaugeValue, state.Purity, labels...)
+				ch <- prometheus.MustNewConstMetric(airTemperatureDesc, prometheus.GaugeValue, state.Temperature, labels...)
+				ch <- prometheus.MustNewConstMetric(airHumidityDesc, prometheus.GaugeValue, state.Humidity, labels...)
+
+				ratings := map[string]string{
+					"combined":    state.CombinedRating,
+					"temperature": state.TemperatureRating,
+					"humidity":    state.HumidityRating,
+					"purity":      state.PurityRating,
+				}
+				for rating, value := range ratings {
+					if number, ok := ratingValues[value]; ok {
+						ch <- prometheus.MustNewConstMetric(airRatingDesc, prometheus.GaugeValue, number, withLabels(labels, rating)...)
+					}
+					collectStateSet(ch, airRatingStateDesc, ratingStates, value, withLabels(labels, rating))
+				}
+			}
+
+		case "SmokeDetectorCheck":
+			var state bshc.SmokeDetectorCheckState
+			if c.SERVICES.SmokeDetector && decodeState(service, &state) {
+				collectStateSet(ch, smokeTestStateDesc, smokeTestStates, state.Value, labels)
+				if passed, ok := hist.smokeTestPassed(service.DeviceID); ok {
+					ch <- prometheus.MustNewConstMetric(smokeTestPassedDesc, prometheus.GaugeValue, float64(passed.Unix()), labels...)
+				}
+			}
+
+		case "Alarm":
+			var state bshc.AlarmState
+			if c.SERVICES.SmokeDetector && decodeState(service, &state) {
+				collectStateSet(ch, alarmStateDesc, alarmStates, state.Value, labels)
+				ch <- prometheus.MustNewConstMetric(alarmActiveDesc, prometheus.GaugeValue, boolToFloat(state.Value != bshc.AlarmIdleOff), labels...)
+			}
+
+		case "SmokeSensitivity":
+			var state bshc.SmokeSensitivityState
+			if c.SERVICES.SmokeDetector && decodeState(service, &state) {
+				collectStateSet(ch, smokeSensitivityDesc, smokeSensitivityStates, state.SmokeSensitivity, labels)
+			}
+
+		case "SurveillanceAlarm":
+			var state bshc.SurveillanceAlarmState
+			if c.SERVICES.SmokeDetector && decodeState(service, &state) {
+				collectStateSet(ch, surveillanceAlarmDesc, surveillanceAlarmStates, state.Value, labels)
+			}
+
+		case "LatestMotion":
+			var state bshc.LatestMotionState
+			if c.SERVICES.MotionDetector && decodeState(service, &state) {
+				if !state.LatestMotionDetected.IsZero() {
+					ch <- prometheus.MustNewConstMetric(motionDetectedDesc, prometheus.GaugeValue, float64(state.LatestMotionDetected.Unix()), labels...)
+				}
+				ch <- prometheus.MustNewConstMetric(motionEventsDesc, prometheus.CounterValue, hist.motionCount(service.DeviceID), labels...)
+			}
+
+		case "MultiLevelSensor":
+			var state bshc.MultiLevelSensorState
+			if c.SERVICES.MotionDetector && decodeState(service, &state) && state.Illuminance != nil {
+				ch <- prometheus.MustNewConstMetric(illuminanceDesc, prometheus.GaugeValue, *state.Illuminance, labels...)
+			}
+
+		case "ShutterControl":
+			var state bshc.ShutterControlState
+			if c.SERVICES.ShutterControl && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(shutterLevelDesc, prometheus.GaugeValue, state.Level, labels...)
+				collectStateSet(ch, shutterOperationDesc, operationStates, state.OperationState, labels)
+				ch <- prometheus.MustNewConstMetric(shutterCalibratedDesc, prometheus.GaugeValue, boolToFloat(state.Calibrated), labels...)
+			}
+
+		case "BatteryLevel":
+			if c.SERVICES.BatteryLevel {
+				collectStateSet(ch, batteryStateDesc, batteryStates, service.BatteryState(), labels)
+			}
+
+		case "CommunicationQuality":
+			var state bshc.CommunicationQualityState
+			if c.SERVICES.CommunicationQuality && decodeState(service, &state) {
+				collectStateSet(ch, commQualityDesc, qualityStates, state.Quality, labels)
+			}
+
+		case "HeatingCircuit":
+			var state bshc.HeatingCircuitState
+			if c.SERVICES.HeatingCircuit && decodeState(service, &state) {
+				circuitLabels := []string{service.DeviceID, device.Name}
+				ch <- prometheus.MustNewConstMetric(circuitSetpointDesc, prometheus.GaugeValue, state.SetpointTemperature, circuitLabels...)
+				collectStateSet(ch, circuitOperationModeDesc, operationModeStates, state.OperationMode, circuitLabels)
+				ch <- prometheus.MustNewConstMetric(circuitOverrideActiveDesc, prometheus.GaugeValue, boolToFloat(state.TemperatureOverrideModeActive), circuitLabels...)
+				ch <- prometheus.MustNewConstMetric(circuitOverrideEnabledDesc, prometheus.GaugeValue, boolToFloat(state.TemperatureOverrideFeatureEnabled), circuitLabels...)
+				ch <- prometheus.MustNewConstMetric(circuitEnergySavingDesc, prometheus.GaugeValue, boolToFloat(state.EnergySavingFeatureEnabled), circuitLabels...)
+			}
+
+		case "WaterLeakageSensor":
+			var state bshc.WaterLeakageSensorState
+			if c.SERVICES.WaterLeakage && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(leakageDesc, prometheus.GaugeValue, boolToFloat(state.State == bshc.LeakageDetected), labels...)
+			}
+
+		case "WaterLeakageSensorTilt":
+			var state bshc.WaterLeakageSensorTiltState
+			if c.SERVICES.WaterLeakage && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(leakageTiltSignalDesc, prometheus.GaugeValue, boolToFloat(state.PushNotificationState == bshc.SignalEnabled), withLabels(labels, "push_notification")...)
+				ch <- prometheus.MustNewConstMetric(leakageTiltSignalDesc, prometheus.GaugeValue, boolToFloat(state.AcousticSignalState == bshc.SignalEnabled), withLabels(labels, "acoustic")...)
+			}
+
+		case "WaterLeakageSensorCheck":
+			var state bshc.WaterLeakageSensorCheckState
+			if c.SERVICES.WaterLeakage && decodeState(service, &state) {
+				collectStateSet(ch, leakageCheckDesc, nil, state.Result, labels)
+			}
+
+		case "BinarySwitch":
+			var state bshc.BinarySwitchState
+			if c.SERVICES.LightControl && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(lightOnDesc, prometheus.GaugeValue, boolToFloat(state.On), labels...)
+			}
+
+		case "MultiLevelSwitch":
+			var state bshc.MultiLevelSwitchState
+			if c.SERVICES.LightControl && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(lightLevelDesc, prometheus.GaugeValue, state.Level, labels...)
+			}
+
+		case "HueColorTemperature":
+			var state bshc.HueColorTemperatureState
+			if c.SERVICES.LightControl && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(lightColorTemperatureDesc, prometheus.GaugeValue, state.ColorTemperature, labels...)
+			}
+
+		case "HSBColorActuator":
+			var state bshc.HSBColorActuatorState
+			if c.SERVICES.LightControl && decodeState(service, &state) {
+				hue, saturation := state.HueSaturation()
+				ch <- prometheus.MustNewConstMetric(lightHueDesc, prometheus.GaugeValue, hue, labels...)
+				ch <- prometheus.MustNewConstMetric(lightSaturationDesc, prometheus.GaugeValue, saturation, labels...)
+			}
+
+		case "Thermostat":
+			var state bshc.ThermostatState
+			if c.SERVICES.Thermostat && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(childLockDesc, prometheus.GaugeValue, boolToFloat(state.ChildLock == bshc.ChildLockOn), labels...)
+			}
+
+		case "TemperatureOffset":
+			var state bshc.TemperatureOffsetState
+			if c.SERVICES.Thermostat && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(temperatureOffsetDesc, prometheus.GaugeValue, state.Offset, labels...)
+				ch <- prometheus.MustNewConstMetric(temperatureOffsetStepDesc, prometheus.GaugeValue, state.StepSize, labels...)
+				ch <- prometheus.MustNewConstMetric(temperatureOffsetMinDesc, prometheus.GaugeValue, state.MinOffset, labels...)
+				ch <- prometheus.MustNewConstMetric(temperatureOffsetMaxDesc, prometheus.GaugeValue, state.MaxOffset, labels...)
+			}
+
+		case "DisplayConfiguration":
+			var state bshc.DisplayConfigurationState
+			if c.SERVICES.Thermostat && decodeState(service, &state) {
+				ch <- prometheus.MustNewConstMetric(displayBrightnessDesc, prometheus.GaugeValue, state.DisplayBrightness, labels...)
+				ch <- prometheus.MustNewConstMetric(displayOnTimeDesc, prometheus.GaugeValue, state.DisplayOnTime, labels...)
+			}
+
+		case "DisplayDirection":
+			var state bshc.DisplayDirectionState
+			if c.SERVICES.Thermostat && decodeState(service, &state) {
+				collectStateSet(ch, displayDirectionDesc, displayDirectionStates, state.Direction, labels)
+			}
+
+		case "DisplayedTemperatureConfiguration":
+			var state bshc.DisplayedTemperatureConfigurationState
+			if c.SERVICES.Thermostat && decodeState(service, &state) {
+				collectStateSet(ch, displayedTemperatureDesc, displayedTemperatureStates, state.DisplayedTemperature, labels)
+			}
+		}
+	}
+
+	for _, windows := range openWindows {
+		ch <- prometheus.MustNewConstMetric(roomOpenWindowsDesc, prometheus.GaugeValue, windows.sum, windows.room.ID, windows.room.Name)
+	}
+
+	if c.SERVICES.Rooms {
+		collectRooms(ch, temperatures, humidities, valves)
+	}
+
+	if c.SERVICES.IntrusionDetection {
+		collectIntrusion(ch)
+	}
+	if c.SERVICES.Controller {
+		collectController(ch)
+	}
+}
+
+// Publish the state of the room climate control
+func collectClimateControl(ch chan<- prometheus.Metric, state bshc.RoomClimateControlState, labels []string) {
+	if state.SetpointTemperatureForLevelEco != nil {
+		ch <- prometheus.MustNewConstMetric(ecoTemperatureDesc, prometheus.GaugeValue, *state.SetpointTemperatureForLevelEco, labels...)
+	}
+	if state.SetpointTemperatureForLevelComfort != nil {
+		ch <- prometheus.MustNewConstMetric(comfortTemperatureDesc, prometheus.GaugeValue, *state.SetpointTemperatureForLevelComfort, labels...)
+	}
+	collectStateSet(ch, operationModeDesc, operationModeStates, state.OperationMode, labels)
+	collectStateSet(ch, roomControlModeDesc, roomControlModeStates, state.RoomControlMode, labels)
+	ch <- prometheus.MustNewConstMetric(boostModeDesc, prometheus.GaugeValue, boolToFloat(state.BoostMode), labels...)
+	ch <- prometheus.MustNewConstMetric(supportsBoostModeDesc, prometheus.GaugeValue, boolToFloat(state.SupportsBoostMode), labels...)
+	ch <- prometheus.MustNewConstMetric(summerModeDesc, prometheus.GaugeValue, boolToFloat(state.SummerMode), labels...)
+	ch <- prometheus.MustNewConstMetric(ventilationModeDesc, prometheus.GaugeValue, boolToFloat(state.VentilationMode), labels...)
+	ch <- prometheus.MustNewConstMetric(lowDesc, prometheus.GaugeValue, boolToFloat(state.Low), labels...)
+
+	// Switch points refer to the local time of the BSHC
+	if state.Schedule != nil {
+		if point, ok := state.Schedule.Current(time.Now(), bshcLocation); ok {
+			collectStateSet(ch, scheduleLevelDesc, scheduleLevelStates, point.Value.TemperatureLevel, labels)
+			ch <- prometheus.MustNewConstMetric(scheduleSwitchPointDesc, prometheus.GaugeValue, float64(point.StartTimeMinutes), labels...)
+		}
+	}
+}
+
+// Publish the state of the intrusion detection system
+func collectIntrusion(ch chan<- prometheus.Metric) {
+	state := current.getIntrusion()
+	if state == nil {
+		return
+	}
+
+	profile, ok := profileNames[state.ActiveConfigurationProfile.ProfileID]
+	if !ok {
+		profile = state.ActiveConfigurationProfile.ProfileID
+	}
+
+	ch <- prometheus.MustNewConstMetric(intrusionAvailableDesc, prometheus.GaugeValue, boolToFloat(state.SystemAvailability.Available))
+	collectStateSet(ch, intrusionArmingDesc, armingStates, state.ArmingState.State, nil)
+	collectStateSet(ch, intrusionAlarmDesc, intrusionAlarmStates, state.AlarmState.Value, nil)
+	collectStateSet(ch, intrusionProfileDesc, profileStates, profile, nil)
+	ch <- prometheus.MustNewConstMetric(intrusionIncidentsDesc, prometheus.GaugeValue, float64(len(state.AlarmState.Incidents)))
+	ch <- prometheus.MustNewConstMetric(intrusionAlarmsDesc, prometheus.CounterValue, hist.alarmCount())
+	ch <- prometheus.MustNewConstMetric(intrusionSuccessDesc, prometheus.GaugeValue, float64(current.intrusionUpdated().Unix()))
+}
+
+// Publish the information about the rooms and the values aggregated per room
+func collectRooms(ch chan<- prometheus.Metric, temperatures, humidities, valves map[string]*roomAggregate) {
+	for _, room := range topo.roomList() {
+		ch <- prometheus.MustNewConstMetric(roomInfoDesc, prometheus.GaugeValue, 1, room.ID, room.Name, room.IconID)
+	}
+
+	devices := make(map[string]*roomAggregate)
+	for _, placed := range topo.list() {
+		aggregate(devices, placed.device, placed.room, 1)
+	}
+	for _, device := range devices {
+		ch <- prometheus.MustNewConstMetric(roomDevicesDesc, prometheus.GaugeValue, float64(device.count), device.room.ID, device.room.Name)
+	}
+
+	for _, temperature := range temperatures {
+		labels := []string{temperature.room.ID, temperature.room.Name}
+		ch <- prometheus.MustNewConstMetric(roomTemperatureAverageDesc, prometheus.GaugeValue, temperature.average(), labels...)
+		ch <- prometheus.MustNewConstMetric(roomTemperatureMinDesc, prometheus.GaugeValue, temperature.min, labels...)
+		ch <- prometheus.MustNewConstMetric(roomTemperatureMaxDesc, prometheus.GaugeValue, temperature.max, labels...)
+	}
+	for _, humidity := range humidities {
+		ch <- prometheus.MustNewConstMetric(roomHumidityAverageDesc, prometheus.GaugeValue, humidity.average(), humidity.room.ID, humidity.room.Name)
+	}
+	for _, valve := range valves {
+		ch <- prometheus.MustNewConstMetric(roomValveAverageDesc, prometheus.GaugeValue, valve.average(), valve.room.ID, valve.room.Name)
+	}
+}
+
+// Publish the information about the BSHC
+func collectController(ch chan<- prometheus.Metric) {
+	info := current.getInformation()
+	if info == nil {
+		return
+	}
+
+	update := info.SoftwareUpdateState
+	ch <- prometheus.MustNewConstMetric(controllerInfoDesc, prometheus.GaugeValue, 1,
+		update.InstalledVersion, info.Generation, strings.Join(info.APIVersions, ","), info.IPAddress, info.MACAddress, info.CountryCode,
+	)
+	collectStateSet(ch, controllerUpdateStateDesc, updateStates, update.State, nil)
+	ch <- prometheus.MustNewConstMetric(controllerUpdateAvailableDesc, prometheus.GaugeValue, boolToFloat(update.State == bshc.UpdateAvailable), update.AvailableVersion)
+
+	// Older controller software does not report its backend connectivity
+	if info.BackendConnectivity != "" {
+		ch <- prometheus.MustNewConstMetric(controllerCloudConnectedDesc, prometheus.GaugeValue, boolToFloat(info.BackendConnectivity == bshc.BackendConnected))
+	}
+	for feature, enabled := range info.FeatureToggles {
+		ch <- prometheus.MustNewConstMetric(controllerFeatureDesc, prometheus.GaugeValue, boolToFloat(enabled), feature)
+	}
+	ch <- prometheus.MustNewConstMetric(controllerSuccessDesc, prometheus.GaugeValue, float64(current.informationUpdated().Unix()))
+}
+
+// Publish the information about a device, listing its services sorted by id
+func collectDeviceInfo(ch chan<- prometheus.Metric, placed placedDevice) {
+	device := placed.device
+	services := append([]string(nil), device.DeviceServiceIDs...)
+	sort.Strings(services)
+
+	ch <- prometheus.MustNewConstMetric(deviceInfoDesc, prometheus.GaugeValue, 1,
+		device.ID, device.Name, placed.room.Name,
+		device.DeviceModel, device.Manufacturer, device.Serial, device.Profile, device.ParentDeviceID, device.IconID,
+		strings.Join(services, ","),
+	)
+}
+
+// Aggregation of values of the devices in a room
+type roomAggregate struct {
+	room  bshc.Room
+	sum   float64
+	min   float64
+	max   float64
+	count int
+}
+
+// Add a value of a device in the room
+func (a *roomAggregate) add(value float64) {
+	if a.count == 0 || value < a.min {
+		a.min = value
+	}
+	if a.count == 0 || value > a.max {
+		a.max = value
+	}
+	a.sum += value
+	a.count++
+}
+
+// Average of the values of the room
+func (a *roomAggregate) average() float64 {
+	return a.sum / float64(a.count)
+}
+
+// Add a value of a device to the aggregation of its room, keyed by the room
+// id as names need not be unique. Devices without a room and the virtual room
+// climate control, which repeats the values of the thermostats, are left out.
+func aggregate(aggregates map[string]*roomAggregate, device bshc.Device, room bshc.Room, value float64) {
+	if room.ID == "" || device.DeviceModel == "ROOM_CLIMATE_CONTROL" {
+		return
+	}
+	if aggregates[room.ID] == nil {
+		aggregates[room.ID] = &roomAggregate{room: room}
+	}
+	aggregates[room.ID].add(value)
+}
+
+// Copy labels and append further ones
+func withLabels(labels []string, extra ...string) []string {
+	result := make([]string, 0, len(labels)+len(extra))
+	result = append(result, labels...)
+	return append(result, extra...)
+}
+
+// Publish one series per possible state with 1 for the current state and 0
+// for all others, the state being the last label of the metric. States not
+// known to the exporter are published as well while they are current.
+func collectStateSet(ch chan<- prometheus.Metric, desc *prometheus.Desc, states []string, value string, labels []string) {
+	known := false
+	for _, state := range states {
+		known = known || state == value
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, boolToFloat(state == value), withLabels(labels, state)...)
+	}
+	if !known && value != "" {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, withLabels(labels, value)...)
+	}
+}
+
+// Convert a boolean to a metric value
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Decode the state of a service and log invalid ones
+func decodeState(service bshc.DeviceService, state interface{}) bool {
+	if err := service.DecodeState(state); err != nil {
+		logger.Errorf("Invalid state format for service %s of device %s: %v", service.ID, service.DeviceID, err)
+		return false
+	}
+	return true
+}
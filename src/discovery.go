@@ -0,0 +1,79 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Strings looking like enum values, e.g. OPEN or IDLE_OFF. Other strings such
+// as ids and timestamps are left out to keep the number of series bounded.
+var enumPattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+var (
+	serviceStateDesc = prometheus.NewDesc(
+		"bshc_service_state",
+		"Numeric and boolean fields of all service states, exported in discovery mode",
+		withLabels(deviceLabels, "service_id", "field"), nil,
+	)
+	serviceStateInfoDesc = prometheus.NewDesc(
+		"bshc_service_state_info",
+		"Enum fields of all service states with the current value as label, exported in discovery mode",
+		withLabels(deviceLabels, "service_id", "field", "value"), nil,
+	)
+)
+
+// Collector publishing every field of every service state of the current
+// snapshot, to see what devices report before they are supported
+type discoveryCollector struct{}
+
+func (discoveryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- serviceStateDesc
+	ch <- serviceStateInfoDesc
+}
+
+func (discoveryCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, service := range current.get() {
+		device, room, ok := topo.lookup(service.DeviceID)
+		if !ok {
+			continue
+		}
+		labels := []string{service.DeviceID, device.Name, room.Name, service.ID}
+
+		var state map[string]interface{}
+		if len(service.State) == 0 || !decodeState(service, &state) {
+			continue
+		}
+		collectFields(ch, state, "", labels)
+	}
+}
+
+// Publish the fields of a decoded state object, naming fields of nested
+// objects by their path separated by dots. Arrays are left out.
+func collectFields(ch chan<- prometheus.Metric, object map[string]interface{}, prefix string, labels []string) {
+	// Sort the keys so the series keep their order between scrapes
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if key != "@type" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		field := prefix + key
+		switch value := object[key].(type) {
+		case float64:
+			ch <- prometheus.MustNewConstMetric(serviceStateDesc, prometheus.GaugeValue, value, withLabels(labels, field)...)
+		case bool:
+			ch <- prometheus.MustNewConstMetric(serviceStateDesc, prometheus.GaugeValue, boolToFloat(value), withLabels(labels, field)...)
+		case string:
+			if enumPattern.MatchString(value) {
+				ch <- prometheus.MustNewConstMetric(serviceStateInfoDesc, prometheus.GaugeValue, 1, withLabels(labels, field, value)...)
+			}
+		case map[string]interface{}:
+			collectFields(ch, value, field+".", labels)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
+)
+
+// Last observed value of a service and when it changed, zero if it has not
+// changed since it was first seen
+type observation struct {
+	value   string
+	changed time.Time
+}
+
+// State changes observed across snapshots
+type history struct {
+	mu           sync.RWMutex
+	contacts     map[string]observation
+	smokeTests   map[string]observation
+	testsPassed  map[string]time.Time
+	motions      map[string]observation
+	motionEvents map[string]float64
+	alarm        string
+	alarms       float64
+}
+
+func newHistory() *history {
+	return &history{
+		contacts:     make(map[string]observation),
+		smokeTests:   make(map[string]observation),
+		testsPassed:  make(map[string]time.Time),
+		motions:      make(map[string]observation),
+		motionEvents: make(map[string]float64),
+	}
+}
+
+// Record the value of a device and report whether it changed. The first
+// value seen for a device is not a change.
+func record(observations map[string]observation, deviceID, value string, now time.Time) bool {
+	previous, ok := observations[deviceID]
+	if !ok {
+		observations[deviceID] = observation{value: value}
+		return false
+	}
+	if previous.value == value {
+		return false
+	}
+	observations[deviceID] = observation{value: value, changed: now}
+	return true
+}
+
+// Record state changes of the given services, which are events from the
+// event stream if events is set and a full fetch otherwise
+func (h *history) observe(services []bshc.DeviceService, events bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for _, service := range services {
+		switch service.ID {
+		case "ShutterContact":
+			var state bshc.ShutterContactState
+			if service.DecodeState(&state) == nil {
+				record(h.contacts, service.DeviceID, state.Value, now)
+			}
+
+		case "SmokeDetectorCheck":
+			var state bshc.SmokeDetectorCheckState
+			if service.DecodeState(&state) == nil {
+				// Full fetches only show a test passing by a change of the value, while
+				// the event stream reports every passed test
+				changed := record(h.smokeTests, service.DeviceID, state.Value, now)
+				if state.Value == bshc.SmokeTestOK && (changed || events) {
+					h.testsPassed[service.DeviceID] = now
+				}
+			}
+
+		case "LatestMotion":
+			var state bshc.LatestMotionState
+			if service.DecodeState(&state) == nil && !state.LatestMotionDetected.IsZero() {
+				if record(h.motions, service.DeviceID, state.LatestMotionDetected.Format(time.RFC3339Nano), now) {
+					h.motionEvents[service.DeviceID]++
+				}
+			}
+		}
+	}
+}
+
+// Time of the last state change of a contact, not known until the contact
+// changed after the exporter started
+func (h *history) contactChanged(deviceID string) (time.Time, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	contact := h.contacts[deviceID]
+	return contact.changed, !contact.changed.IsZero()
+}
+
+// Count alarms of the intrusion detection system. Unmuting a running alarm
+// does not count as a new one.
+func (h *history) observeIntrusion(state *bshc.IntrusionSystemState) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if state.AlarmState.Value == bshc.IntrusionAlarmOn && (h.alarm == bshc.IntrusionAlarmOff || h.alarm == bshc.IntrusionPreAlarm) {
+		h.alarms++
+	}
+	h.alarm = state.AlarmState.Value
+}
+
+// Number of alarms of the intrusion detection system since the exporter
+// started
+func (h *history) alarmCount() float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.alarms
+}
+
+// Number of motions a motion detector reported since the exporter started
+func (h *history) motionCount(deviceID string) float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.motionEvents[deviceID]
+}
+
+// Time a smoke detector last reported a successful self-test, not known until
+// a test passed after the exporter started
+func (h *history) smokeTestPassed(deviceID string) (time.Time, bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	passed, ok := h.testsPassed[deviceID]
+	return passed, ok
+}
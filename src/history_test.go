@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
+)
+
+func TestObserveIntrusion(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []string
+		alarms float64
+	}{
+		{name: "alarm", states: []string{bshc.IntrusionAlarmOff, bshc.IntrusionAlarmOn}, alarms: 1},
+		{name: "pre-alarm", states: []string{bshc.IntrusionAlarmOff, bshc.IntrusionPreAlarm, bshc.IntrusionAlarmOn}, alarms: 1},
+		{name: "running at start", states: []string{bshc.IntrusionAlarmOn, bshc.IntrusionAlarmOff}, alarms: 0},
+		{name: "muted and unmuted", states: []string{bshc.IntrusionAlarmOff, bshc.IntrusionAlarmOn, bshc.IntrusionAlarmMuted, bshc.IntrusionAlarmOn}, alarms: 1},
+		{name: "two alarms", states: []string{bshc.IntrusionAlarmOff, bshc.IntrusionAlarmOn, bshc.IntrusionAlarmOff, bshc.IntrusionAlarmOn}, alarms: 2},
+		{name: "unchanged", states: []string{bshc.IntrusionAlarmOn, bshc.IntrusionAlarmOn}, alarms: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHistory()
+			for _, value := range tt.states {
+				var state bshc.IntrusionSystemState
+				state.AlarmState.Value = value
+				h.observeIntrusion(&state)
+			}
+			if got := h.alarmCount(); got != tt.alarms {
+				t.Errorf("alarmCount() = %v, want %v", got, tt.alarms)
+			}
+		})
+	}
+}
+
+func TestSmokeTestPassed(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		events bool
+		passed bool
+	}{
+		{name: "passed before start", values: []string{bshc.SmokeTestOK}, passed: false},
+		{name: "unchanged", values: []string{bshc.SmokeTestOK, bshc.SmokeTestOK}, passed: false},
+		{name: "requested test passed", values: []string{bshc.SmokeTestRequested, bshc.SmokeTestOK}, passed: true},
+		{name: "test failed", values: []string{bshc.SmokeTestOK, bshc.SmokeTestFailed}, passed: false},
+		{name: "event of passed test", values: []string{bshc.SmokeTestOK, bshc.SmokeTestOK}, events: true, passed: true},
+		{name: "first event", values: []string{bshc.SmokeTestOK}, events: true, passed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newHistory()
+			for _, value := range tt.values {
+				state, _ := json.Marshal(bshc.SmokeDetectorCheckState{Value: value})
+				h.observe([]bshc.DeviceService{{ID: "SmokeDetectorCheck", DeviceID: "hdm:Smoke:1", State: state}}, tt.events)
+			}
+			if _, passed := h.smokeTestPassed("hdm:Smoke:1"); passed != tt.passed {
+				t.Errorf("smokeTestPassed() = %v, want %v", passed, tt.passed)
+			}
+		})
+	}
+}
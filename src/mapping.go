@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Valid names of metrics defined in the config file
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Metric defined in the config file for a field of a service state
+type metricMapping struct {
+	ID     string             `yaml:"id"`
+	Path   string             `yaml:"path"`
+	Name   string             `yaml:"name"`
+	Help   string             `yaml:"help"`
+	Type   string             `yaml:"type"`
+	Values map[string]float64 `yaml:"values"`
+}
+
+// Metric defined in the config file, ready to be collected
+type mappedMetric struct {
+	serviceID string
+	field     string
+	path      []string
+	values    map[string]float64
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+	shared    bool
+}
+
+// Collector publishing the metrics defined in the config file from the
+// current snapshot
+type mappingCollector struct {
+	descs    []*prometheus.Desc
+	services map[string][]mappedMetric
+}
+
+// Validate the metrics defined in the config file and create a collector for
+// them. Several entries may share a metric name if help and type match, the
+// help of the first entry is used when later ones leave it out. Shared
+// metrics are labeled with the service id and field to keep their series
+// apart on devices offering more than one of the services.
+func newMappingCollector(mappings []metricMapping) (*mappingCollector, error) {
+	collector := &mappingCollector{services: make(map[string][]mappedMetric)}
+	defined := make(map[string]mappedMetric)
+	help := make(map[string]string)
+	fields := make(map[string]bool)
+
+	entries := make(map[string]int)
+	for _, mapping := range mappings {
+		entries[mapping.Name]++
+	}
+
+	for i, mapping := range mappings {
+		if mapping.ID == "" || mapping.Path == "" || mapping.Name == "" {
+			return nil, fmt.Errorf("metric %d: id, path and name are required", i+1)
+		}
+		if !metricNamePattern.MatchString(mapping.Name) {
+			return nil, fmt.Errorf("metric %s: invalid name", mapping.Name)
+		}
+
+		field := mapping.Name + " " + mapping.ID + " " + mapping.Path
+		if fields[field] {
+			return nil, fmt.Errorf("metric %s: %s of the %s service listed more than once", mapping.Name, mapping.Path, mapping.ID)
+		}
+		fields[field] = true
+
+		var valueType prometheus.ValueType
+		switch mapping.Type {
+		case "", "gauge":
+			valueType = prometheus.GaugeValue
+		case "counter":
+			valueType = prometheus.CounterValue
+		default:
+			return nil, fmt.Errorf("metric %s: unknown type %q, must be gauge or counter", mapping.Name, mapping.Type)
+		}
+
+		metric, ok := defined[mapping.Name]
+		if !ok {
+			if mapping.Help == "" {
+				mapping.Help = fmt.Sprintf("Value of %s of the %s service", mapping.Path, mapping.ID)
+			}
+			labels := deviceLabels
+			if entries[mapping.Name] > 1 {
+				labels = withLabels(deviceLabels, "service_id", "field")
+			}
+			metric = mappedMetric{
+				desc:      prometheus.NewDesc(mapping.Name, mapping.Help, labels, nil),
+				valueType: valueType,
+				shared:    entries[mapping.Name] > 1,
+			}
+			defined[mapping.Name] = metric
+			help[mapping.Name] = mapping.Help
+			collector.descs = append(collector.descs, metric.desc)
+		} else if (mapping.Help != "" && help[mapping.Name] != mapping.Help) || metric.valueType != valueType {
+			return nil, fmt.Errorf("metric %s: defined more than once with different help or type", mapping.Name)
+		}
+
+		metric.serviceID = mapping.ID
+		metric.field = mapping.Path
+		metric.path = strings.Split(mapping.Path, ".")
+		metric.values = mapping.Values
+		collector.services[mapping.ID] = append(collector.services[mapping.ID], metric)
+	}
+	return collector, nil
+}
+
+func (m *mappingCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range m.descs {
+		ch <- desc
+	}
+}
+
+func (m *mappingCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, service := range current.get() {
+		metrics, ok := m.services[service.ID]
+		if !ok {
+			continue
+		}
+		device, room, ok := topo.lookup(service.DeviceID)
+		if !ok {
+			continue
+		}
+		labels := []string{service.DeviceID, device.Name, room.Name}
+
+		var state interface{}
+		if !decodeState(service, &state) {
+			continue
+		}
+
+		for _, metric := range metrics {
+			field, ok := statePath(state, metric.path)
+			if !ok {
+				continue
+			}
+			value, ok := stateValue(field, metric.values)
+			if !ok {
+				logger.Debugf("Cannot convert %v of service %s of device %s to a metric value", field, service.ID, service.DeviceID)
+				continue
+			}
+			if metric.shared {
+				ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, value, withLabels(labels, metric.serviceID, metric.field)...)
+			} else {
+				ch <- prometheus.MustNewConstMetric(metric.desc, metric.valueType, value, labels...)
+			}
+		}
+	}
+}
+
+// Follow a path of object keys into a decoded service state
+func statePath(state interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		object, ok := state.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if state, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return state, true
+}
+
+// Convert a field of a decoded service state to a metric value. Strings are
+// looked up in values, booleans become 1 or 0.
+func stateValue(field interface{}, values map[string]float64) (float64, bool) {
+	switch field := field.(type) {
+	case float64:
+		return field, true
+	case bool:
+		return boolToFloat(field), true
+	case string:
+		value, ok := values[field]
+		return value, ok
+	}
+	return 0, false
+}
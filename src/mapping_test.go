@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/withmandala/go-log"
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
+)
+
+func TestNewMappingCollector(t *testing.T) {
+	tests := []struct {
+		name     string
+		mappings []metricMapping
+		wantErr  bool
+	}{
+		{
+			name:     "valid gauge",
+			mappings: []metricMapping{{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp"}},
+		},
+		{
+			name:     "valid counter",
+			mappings: []metricMapping{{ID: "PowerMeter", Path: "energyConsumption", Name: "bshc_custom_energy_total", Type: "counter"}},
+		},
+		{
+			name:     "missing id",
+			mappings: []metricMapping{{Path: "temperature", Name: "bshc_custom_temp"}},
+			wantErr:  true,
+		},
+		{
+			name:     "missing path",
+			mappings: []metricMapping{{ID: "TemperatureLevel", Name: "bshc_custom_temp"}},
+			wantErr:  true,
+		},
+		{
+			name:     "missing name",
+			mappings: []metricMapping{{ID: "TemperatureLevel", Path: "temperature"}},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid name",
+			mappings: []metricMapping{{ID: "TemperatureLevel", Path: "temperature", Name: "bshc-bad name"}},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown type",
+			mappings: []metricMapping{{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp", Type: "histogram"}},
+			wantErr:  true,
+		},
+		{
+			name: "shared name",
+			mappings: []metricMapping{
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp", Help: "Temperature"},
+				{ID: "AirQualityLevel", Path: "temperature", Name: "bshc_custom_temp"},
+			},
+		},
+		{
+			name: "shared name with different help",
+			mappings: []metricMapping{
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp", Help: "Temperature"},
+				{ID: "AirQualityLevel", Path: "temperature", Name: "bshc_custom_temp", Help: "Air temperature"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "shared name with different type",
+			mappings: []metricMapping{
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp"},
+				{ID: "AirQualityLevel", Path: "temperature", Name: "bshc_custom_temp", Type: "counter"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same field listed twice",
+			mappings: []metricMapping{
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp"},
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "same field with different names",
+			mappings: []metricMapping{
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp"},
+				{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temperature"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newMappingCollector(tt.mappings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newMappingCollector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMappingCollectorSharedName(t *testing.T) {
+	logger = log.New(os.Stderr)
+	topo.devices = map[string]bshc.Device{"hdm:Twinguard:1": {ID: "hdm:Twinguard:1", Name: "Twinguard", RoomID: "hz_1"}}
+	topo.rooms = map[string]bshc.Room{"hz_1": {ID: "hz_1", Name: "Living room"}}
+	current.set([]bshc.DeviceService{
+		{ID: "TemperatureLevel", DeviceID: "hdm:Twinguard:1", State: json.RawMessage(`{"temperature": 21.5}`)},
+		{ID: "AirQualityLevel", DeviceID: "hdm:Twinguard:1", State: json.RawMessage(`{"temperature": 21.7, "purity": 600}`)},
+	})
+
+	collector, err := newMappingCollector([]metricMapping{
+		{ID: "TemperatureLevel", Path: "temperature", Name: "bshc_custom_temp", Help: "Temperature"},
+		{ID: "AirQualityLevel", Path: "temperature", Name: "bshc_custom_temp"},
+	})
+	if err != nil {
+		t.Fatalf("newMappingCollector() error = %v", err)
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	if err := registry.Register(collector); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	if len(families) != 1 || len(families[0].GetMetric()) != 2 {
+		t.Fatalf("Gather() = %v, want 2 series of bshc_custom_temp", families)
+	}
+}
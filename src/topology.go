@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"plasticghoul.de/bshc-prometheus-exporter/bshc"
+)
+
+// Minimum time between two refreshes triggered by unknown devices
+const topologyRefreshBackoff = time.Minute
+
+// Devices and rooms known to the BSHC
+type topology struct {
+	mu          sync.RWMutex
+	devices     map[string]bshc.Device
+	rooms       map[string]bshc.Room
+	ignored     map[string]bool
+	lastAttempt time.Time
+	lastStatus  time.Time
+}
+
+// Fetch devices and rooms from the BSHC and replace the known ones
+func (t *topology) refresh(ctx context.Context) error {
+	logger.Info("Fetching devices and rooms")
+
+	t.mu.Lock()
+	t.lastAttempt = time.Now()
+	t.mu.Unlock()
+
+	devicesArray, err := client.Devices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+	roomsArray, err := client.Rooms(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get rooms: %w", err)
+	}
+
+	// Save devices by id
+	devices := make(map[string]bshc.Device)
+	ignored := make(map[string]bool)
+	for _, device := range devicesArray {
+		if device.DeviceModel == "VENTILATION_SERVICE" {
+			ignored[device.ID] = true
+			continue
+		}
+
+		devices[device.ID] = device
+		logger.Debugf("Device added: ID=%s, Name=%s, RoomID=%s", device.ID, device.Name, device.RoomID)
+	}
+
+	// Save rooms by id
+	rooms := make(map[string]bshc.Room)
+	for _, room := range roomsArray {
+		rooms[room.ID] = room
+		logger.Debugf("Room added: ID=%s, Name=%s", room.ID, room.Name)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.devices = devices
+	t.rooms = rooms
+	t.ignored = ignored
+
+	logger.Infof("Fetched %d devices and %d rooms", len(devices), len(rooms))
+	return nil
+}
+
+// Refresh devices and rooms if one of the services belongs to an unknown
+// device, at most once per topologyRefreshBackoff
+func (t *topology) refreshUnknown(ctx context.Context, services []bshc.DeviceService) {
+	t.mu.RLock()
+	unknown := ""
+	for _, service := range services {
+		if service.DeviceID == "" {
+			continue
+		}
+		if _, ok := t.devices[service.DeviceID]; !ok && !t.ignored[service.DeviceID] {
+			unknown = service.DeviceID
+			break
+		}
+	}
+	recent := time.Since(t.lastAttempt) < topologyRefreshBackoff
+	t.mu.RUnlock()
+
+	if unknown == "" || recent {
+		return
+	}
+
+	logger.Infof("Found unknown device %s, refreshing devices and rooms", unknown)
+	if err := t.refresh(ctx); err != nil {
+		logger.Errorf("Failed to refresh devices and rooms: %v", err)
+	}
+}
+
+// Fetch devices from the BSHC and update the status of the known ones, so
+// devices dropping off show up between refreshes of the topology
+func (t *topology) refreshStatus(ctx context.Context) error {
+	t.mu.Lock()
+	t.lastStatus = time.Now()
+	t.mu.Unlock()
+
+	devicesArray, err := client.Devices(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get devices: %w", err)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, device := range devicesArray {
+		if known, ok := t.devices[device.ID]; ok && known.Status != device.Status {
+			logger.Debugf("Device status changed: ID=%s, Status=%s", device.ID, device.Status)
+			known.Status = device.Status
+			t.devices[device.ID] = known
+		}
+	}
+	return nil
+}
+
+// Whether the status of the devices was last fetched more than interval ago
+func (t *topology) statusDue(interval time.Duration) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return time.Since(t.lastStatus) >= interval
+}
+
+// Resolve a device and its room
+func (t *topology) lookup(deviceID string) (bshc.Device, bshc.Room, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	device, ok := t.devices[deviceID]
+	if !ok {
+		if !t.ignored[deviceID] {
+			logger.Debugf("Unknown device: %s", deviceID)
+		}
+		return bshc.Device{}, bshc.Room{}, false
+	}
+	room, ok := t.room(device)
+	if !ok {
+		return bshc.Device{}, bshc.Room{}, false
+	}
+	return device, room, true
+}
+
+// Device together with its room
+type placedDevice struct {
+	device bshc.Device
+	room   bshc.Room
+}
+
+// All known devices whose room is known as well
+func (t *topology) list() []placedDevice {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	placed := make([]placedDevice, 0, len(t.devices))
+	for _, device := range t.devices {
+		if room, ok := t.room(device); ok {
+			placed = append(placed, placedDevice{device: device, room: room})
+		}
+	}
+	return placed
+}
+
+// All known rooms
+func (t *topology) roomList() []bshc.Room {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	rooms := make([]bshc.Room, 0, len(t.rooms))
+	for _, room := range t.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Resolve the room of a device, the caller must hold the lock
+func (t *topology) room(device bshc.Device) (bshc.Room, bool) {
+	// Virtual devices like the smoke detection system have no room
+	if device.RoomID == "" {
+		return bshc.Room{}, true
+	}
+	room, ok := t.rooms[device.RoomID]
+	if !ok {
+		logger.Debugf("Unknown room %s for device: %s", device.RoomID, device.ID)
+	}
+	return room, ok
+}
+
+// Refresh devices and rooms in the background
+func refreshTopology(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		if err := topo.refresh(ctx); err != nil {
+			logger.Errorf("Failed to refresh devices and rooms: %v", err)
+		}
+		cancel()
+	}
+}